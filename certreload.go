@@ -0,0 +1,117 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// certManager serves the server certificate through tls.Config.GetCertificate
+// and swaps it when the certificate or key file changes, so renewed certificates
+// are used for new connections without a restart
+type certManager struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	// load loads the key pair, from the files or with the key in a PKCS#11 token
+	load func() (tls.Certificate, error)
+
+	// fingerprint is the modification times and sizes of the files at the last reload
+	fingerprint string
+}
+
+func newCertManager(certFile, keyFile string) (*certManager, error) {
+	return newCertManagerWithLoader(certFile, keyFile, func() (tls.Certificate, error) {
+		return loadX509KeyPair(certFile, keyFile)
+	})
+}
+
+// newServerCertManager returns the certManager of the server key pair, with the key in the file or in a PKCS#11 token
+func newServerCertManager(opt CLIServer) (*certManager, error) {
+	if opt.KeyPKCS11 == "" {
+		return newCertManager(opt.CertFile, opt.KeyFile)
+	}
+	load, err := pkcs11KeyPairLoader(opt.CertFile, opt.KeyPKCS11)
+	if err != nil {
+		return nil, err
+	}
+	return newCertManagerWithLoader(opt.CertFile, "", load)
+}
+
+// newCertManagerWithLoader returns the certManager loading the key pair with load.
+// keyFile is empty if the key is not in a file.
+func newCertManagerWithLoader(certFile, keyFile string, load func() (tls.Certificate, error)) (*certManager, error) {
+	m := &certManager{certFile: certFile, keyFile: keyFile, load: load}
+	m.fingerprint = m.stat()
+	if err := m.reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *certManager) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.cert.Load(), nil
+}
+
+// reload loads the key pair and swaps the certificate.
+// The previous certificate is kept if the files are invalid.
+func (m *certManager) reload() error {
+	cert, err := m.load()
+	if err != nil {
+		return fmt.Errorf("failed to load key pair: %w", err)
+	}
+	m.cert.Store(&cert)
+	return nil
+}
+
+// stat returns the fingerprint of the files, which changes when either of them is rewritten
+func (m *certManager) stat() string {
+	var fp string
+	for _, file := range []string{m.certFile, m.keyFile} {
+		if fi, err := os.Stat(file); err == nil {
+			fp += fmt.Sprintf("%s:%d:%d;", file, fi.ModTime().UnixNano(), fi.Size())
+		}
+	}
+	return fp
+}
+
+// reloadIfChanged reloads the certificate if the files have changed since the last reload.
+// A failed reload is logged once per change of the files and the previous certificate is kept.
+func (m *certManager) reloadIfChanged() {
+	fp := m.stat()
+	if fp == m.fingerprint {
+		return
+	}
+	m.fingerprint = fp
+	if err := m.reload(); err != nil {
+		slog.Error("Failed to reload the certificate, keeping the previous one",
+			"certFile", m.certFile,
+			"keyFile", m.keyFile,
+			"error", err,
+		)
+		return
+	}
+	attrs := []any{"certFile", m.certFile, "keyFile", m.keyFile}
+	if leaf := m.cert.Load().Leaf; leaf != nil {
+		attrs = append(attrs, "not_after", leaf.NotAfter)
+	}
+	slog.Info("Reloaded the certificate", attrs...)
+}
+
+// watch checks the files every interval until ctx is done
+func (m *certManager) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reloadIfChanged()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyCertFiles copies the key pair to certFile and keyFile
+func copyCertFiles(t *testing.T, srcCert, srcKey, certFile, keyFile string) {
+	t.Helper()
+	for src, dst := range map[string]string{srcCert: certFile, srcKey: keyFile} {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", src, err)
+		}
+		if err := os.WriteFile(dst, b, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", dst, err)
+		}
+	}
+}
+
+func currentCert(t *testing.T, m *certManager) []byte {
+	t.Helper()
+	cert, err := m.getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("getCertificate() error = %v", err)
+	}
+	return cert.Certificate[0]
+}
+
+func TestCertManagerReload(t *testing.T) {
+	firstCert, firstKey, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	secondCert, secondKey, cleanup2 := createTempCertFiles(t)
+	defer cleanup2()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	copyCertFiles(t, firstCert, firstKey, certFile, keyFile)
+
+	m, err := newCertManager(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertManager() error = %v", err)
+	}
+	first := currentCert(t, m)
+
+	var buf bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	// Unchanged files are not reloaded
+	m.reloadIfChanged()
+	if buf.Len() > 0 {
+		t.Errorf("Expected no reload for unchanged files, got logs: %s", buf.String())
+	}
+
+	// A renewed certificate is swapped in
+	copyCertFiles(t, secondCert, secondKey, certFile, keyFile)
+	m.reloadIfChanged()
+	renewed := currentCert(t, m)
+	if bytes.Equal(renewed, first) {
+		t.Error("Expected the renewed certificate after reload")
+	}
+	if !strings.Contains(buf.String(), `msg="Reloaded the certificate"`) {
+		t.Errorf("Expected the reload to be logged, got: %s", buf.String())
+	}
+
+	// An invalid certificate keeps the previous one
+	buf.Reset()
+	if err := os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", certFile, err)
+	}
+	m.reloadIfChanged()
+	if !bytes.Equal(currentCert(t, m), renewed) {
+		t.Error("Expected the previous certificate to be kept after an invalid reload")
+	}
+	if !strings.Contains(buf.String(), `level=ERROR msg="Failed to reload the certificate, keeping the previous one"`) {
+		t.Errorf("Expected the failed reload to be logged, got: %s", buf.String())
+	}
+
+	// The failure is logged once until the files change again
+	buf.Reset()
+	m.reloadIfChanged()
+	if buf.Len() > 0 {
+		t.Errorf("Expected no retry for unchanged invalid files, got logs: %s", buf.String())
+	}
+}
+
+func TestCertManagerInvalid(t *testing.T) {
+	certFile, _, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	if _, err := newCertManager(certFile, "/nonexistent/key.pem"); err == nil {
+		t.Error("newCertManager() expected error, got nil")
+	}
+}
+
+func TestRunServerCertReload(t *testing.T) {
+	firstCert, firstKey, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	secondCert, secondKey, cleanup2 := createTempCertFiles(t)
+	defer cleanup2()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	copyCertFiles(t, firstCert, firstKey, certFile, keyFile)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, CLIServer{
+			Address:            address,
+			CertFile:           certFile,
+			KeyFile:            keyFile,
+			CertReloadInterval: 50 * time.Millisecond,
+		})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	check := func(caFile string) error {
+		return runClient(ctx, CLIClient{Address: address, TLS: true, CAFile: caFile, Timeout: time.Second})
+	}
+	if err := check(firstCert); err != nil {
+		t.Fatalf("Expected the first certificate to be served: %v", err)
+	}
+
+	copyCertFiles(t, secondCert, secondKey, certFile, keyFile)
+	deadline := time.Now().Add(3 * time.Second)
+	for check(secondCert) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("The renewed certificate was not served")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := check(firstCert); err == nil {
+		t.Error("Expected the first certificate not to be served after reload")
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+}
@@ -2,55 +2,409 @@ package grpchealth
 
 import (
 	"context"
-	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
 )
 
 type CLIClient struct {
-	Address  string `help:"gRPC client address (e.g., localhost:50051 or unix:///tmp/grpc.sock)" arg:"" required:""`
-	TLS      bool   `help:"Use TLS for connection" short:"t"`
-	Insecure bool   `help:"Use insecure connection" short:"k"`
-	Service  string `help:"Service name to check health status" default:"" short:"s"`
+	Address  string        `help:"gRPC client address (e.g., localhost:50051 or unix:///tmp/grpc.sock), or - to read newline-separated addresses from stdin" arg:"" required:""`
+	TLS      bool          `help:"Use TLS for connection" short:"t"`
+	Insecure bool          `help:"Use insecure connection" short:"k"`
+	Services []string      `help:"Service name to check health status (repeatable)" name:"service" short:"s" placeholder:"SERVICE"`
+	Timeout  time.Duration `help:"Timeout for the health check request, shared by all the services of repeated --service (0 for no timeout)" default:"10s"`
+	Output   string        `help:"Output format (text, json, junit)" enum:"text,json,junit" default:"text" short:"o"`
+
+	Timestamps  bool   `help:"Include the request sent and response received timestamps (RFC3339Nano) in the output"`
+	JSONFile    string `help:"Also write the result of each check as a JSON line to this file, in addition to the --output format" name:"json-file"`
+	Summary     bool   `help:"Print a summary JSON of all the checks at the end of the run"`
+	SummaryFile string `help:"Write the summary JSON to this file instead of stdout (implies --summary)"`
+	Watch       bool   `help:"Watch the health status with the streaming Watch RPC until interrupted" short:"w"`
+	ResetSeq    bool   `help:"Restart the seq of the JSON lines of --watch from 1 when the stream reconnects after a failure (--retries)" name:"reset-seq-on-reconnect"`
+
+	ExpectTransition string        `help:"Watch and succeed only if the status changes as FROM->TO (e.g., NOT_SERVING->SERVING)" placeholder:"FROM->TO"`
+	Within           time.Duration `help:"Time window for --expect-transition" default:"30s"`
+	Interval         time.Duration `help:"Check the health every interval until interrupted (0 to check once)"`
+
+	RefusedAsUnhealthy   bool          `help:"Treat connection refused (Unavailable) as NOT_SERVING instead of an error"`
+	DisableServiceConfig bool          `help:"Ignore the service config provided by the name resolver"`
+	Compression          string        `help:"Compress the request (falls back to no compression if unsupported by the server)" enum:"none,gzip" default:"none"`
+	WarnLatency          time.Duration `help:"Warn if the health check succeeds but takes longer than this duration"`
+	ShowTarget           bool          `help:"Show the canonical target resolved by gRPC"`
+	ShowWireSize         bool          `help:"Show the wire size of the request and response messages"`
+	SRV                  bool          `help:"Resolve the address as a DNS SRV name (e.g., _grpc._tcp.example.com) and check every target" name:"srv"`
+	ComparePeers         bool          `help:"Check every backend the host resolves to and report inconsistencies in status and certificate"`
+	K8sService           string        `help:"Check every ready pod of the Kubernetes Service (namespace/name) in the cluster; the address is the pod port number or name" name:"k8s-service"`
+	KeepaliveTime        time.Duration `help:"Send a keepalive ping after this duration of inactivity (minimum 10s, 0 for no pings)"`
+	KeepaliveTimeout     time.Duration `help:"Close the connection if a keepalive ping is not acknowledged within this duration (0 for the default 20s)"`
+	KeepaliveNoStream    bool          `help:"Send keepalive pings even without active streams" name:"keepalive-permit-without-stream"`
+	UserAgent            string        `help:"User-Agent sent to the server" default:"grpchealth/${version}"`
+	AutoTLS              bool          `help:"Try TLS first and fall back to plaintext if the server does not speak TLS" name:"auto-tls"`
+	PathPrefix           string        `help:"Path prefix under which a gateway mounts the health service (e.g., /api sends /api/grpc.health.v1.Health/Check)"`
+	Relay                string        `help:"Connect through a relay speaking HTTP CONNECT (host:port)"`
+	StatusCodeOnly       bool          `help:"Print only the numeric health status (1: SERVING, 2: NOT_SERVING, 3: SERVICE_UNKNOWN) to stdout"`
+	ConnectOnly          bool          `help:"Only verify that a connection (including the TLS handshake) can be established, without calling Check"`
+	Deadline             time.Time     `help:"Absolute deadline for the health check in RFC3339 (e.g., 2025-01-01T00:00:00Z)"`
+	RawStatus            bool          `help:"Print the full gRPC status proto (code, message, details) as JSON to stdout on failure (in raw_status of the result with --output json)"`
+	FailThresholdPercent float64       `help:"Fail a run over multiple targets (--srv, --k8s-service, or stdin) only if more than this percentage of them are not healthy" default:"0"`
+	InjectClientLatency  time.Duration `help:"Delay each health check call by this duration to test probe timeouts (chaos testing)"`
+	Concurrency          int           `help:"Number of targets read from stdin to check at the same time" default:"1"`
+	Retries              int           `help:"Number of retries on a not serving result or a failed check with a code of --retry-on" default:"0"`
+	RetryInterval        time.Duration `help:"Interval between retries" default:"1s"`
+	RetryJitter          string        `help:"Randomize the interval between retries to spread the retries of many clients (none, full: 0 to the interval, equal: half to the full interval)" enum:"none,full,equal" default:"none"`
+	RetryOn              []string      `help:"gRPC codes of the failed checks to retry (e.g., Unavailable,DeadlineExceeded)" default:"Unavailable,DeadlineExceeded" placeholder:"CODE"`
+	MaxRedials           int           `help:"Give up after this many failed connection attempts (0 for no limit)" default:"0"`
+	ServerName           string        `help:"Server name to verify the server certificate against, instead of the host of the address"`
+	CAFile               string        `help:"Path to a PEM bundle of CA certificates to verify the server certificate" name:"ca-file"`
+	ExpectMTLSRequired   bool          `help:"Check without a client certificate and succeed only if the server rejects the connection (implies --tls)" name:"expect-mtls-required"`
+	Cert                 string        `help:"Path to the client certificate file for mutual TLS"`
+	Key                  string        `help:"Path to the client key file for mutual TLS"`
+	TLSRenegotiation     string        `help:"TLS renegotiation support (never, once, freely)" enum:"never,once,freely" default:"never" name:"tls-renegotiation"`
+	Tenant               string        `help:"Tenant ID sent as routing metadata for multi-tenant backends"`
+	TenantHeader         string        `help:"Metadata key used to send the tenant ID" default:"x-tenant-id"`
+	DeployID             string        `help:"Deploy or release ID included in every result and the summary to correlate the checks with a deploy" name:"deploy-id"`
+	DeployIDHeader       string        `help:"Also send the deploy ID in this metadata key (e.g., x-deploy-id)" name:"deploy-id-header"`
+	Headers              []string      `help:"Metadata header sent with the request (repeatable)" name:"header" short:"H" placeholder:"KEY=VALUE" sep:"none"`
+	Token                string        `help:"Bearer token sent in the authorization metadata"`
+	TokenFile            string        `help:"Path to a file containing the bearer token"`
+
+	// Service is the single service name to check (for library use; --service sets Services)
+	Service string `kong:"-"`
+	// Resolvers are custom resolver builders used only by this client (for library use)
+	Resolvers []resolver.Builder `kong:"-"`
+	// ContextDialer overrides the dialer for TCP and custom resolver targets (for library use)
+	ContextDialer func(context.Context, string) (net.Conn, error) `kong:"-"`
+	// OnEvent receives the lifecycle events of each checked target (for library use)
+	OnEvent func(Event) `kong:"-"`
+	// Stdout receives the results, the status codes and the reports instead of os.Stdout (for library use).
+	// The logs still go to slog.Default().
+	Stdout io.Writer `kong:"-"`
+
+	results  *resultSet      `kong:"-"`
+	jsonFile io.Writer       `kong:"-"`
+	snapshot *healthSnapshot `kong:"-"`
+	// backend is the address actually dialed when it differs from Address (--compare-peers)
+	backend string `kong:"-"`
+	// certificateRequested records whether the server asked for a client certificate (--expect-mtls-required)
+	certificateRequested *atomic.Bool `kong:"-"`
+}
+
+// RunClient checks the health of the target with the options (for library use)
+func RunClient(ctx context.Context, opt CLIClient) error {
+	return runClient(ctx, opt)
+}
+
+// stdout returns the writer of the results
+func (opt CLIClient) stdout() io.Writer {
+	if opt.Stdout != nil {
+		return opt.Stdout
+	}
+	return os.Stdout
 }
 
 func runClient(ctx context.Context, opt CLIClient) error {
+	if opt.FailThresholdPercent < 0 || opt.FailThresholdPercent > 100 {
+		return fmt.Errorf("--fail-threshold-percent must be between 0 and 100")
+	}
+	retryOn, err := retryCodes(opt.RetryOn)
+	if err != nil {
+		return fmt.Errorf("invalid --retry-on: %w", err)
+	}
+	if opt.SummaryFile != "" {
+		opt.Summary = true
+	}
+	if !opt.Deadline.IsZero() {
+		if !opt.Deadline.After(time.Now()) {
+			return fmt.Errorf("deadline %s is not in the future", opt.Deadline.Format(time.RFC3339))
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, opt.Deadline)
+		defer cancel()
+		// nested runClient calls (SRV, auto TLS) share this context
+		opt.Deadline = time.Time{}
+	}
+	if opt.JSONFile != "" && opt.jsonFile == nil {
+		// nested runClient calls write to this file
+		f, err := os.Create(opt.JSONFile)
+		if err != nil {
+			return fmt.Errorf("failed to create the JSON file: %w", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				slog.Warn("Failed to close the JSON file", "file", opt.JSONFile, "error", err)
+			}
+		}()
+		opt.jsonFile = f
+	}
+	if (opt.Output == "junit" || opt.Summary) && opt.results == nil {
+		// nested runClient calls add their results to this set
+		results := &resultSet{}
+		opt.results = results
+		defer func() {
+			writeResults(opt, results.list())
+		}()
+	}
+	if opt.Address == "-" {
+		return runStdinClient(ctx, opt)
+	}
+	if len(opt.Services) == 1 {
+		// before the polling, which logs the service
+		opt.Service, opt.Services = opt.Services[0], nil
+	}
+	if opt.Interval > 0 {
+		return runPollClient(ctx, opt)
+	}
+	if len(opt.Services) > 1 {
+		return runServicesClient(ctx, opt)
+	}
+	if opt.SRV {
+		return runSRVClient(ctx, opt)
+	}
+	if opt.K8sService != "" {
+		return runK8sClient(ctx, opt)
+	}
+	if opt.ComparePeers {
+		return runComparePeersClient(ctx, opt)
+	}
+	if opt.AutoTLS {
+		return runAutoTLSClient(ctx, opt)
+	}
+	if opt.ExpectMTLSRequired {
+		return runExpectMTLSRequiredClient(ctx, opt)
+	}
+	var result *checkResult
+	var seq watchSequence
+	for attempt := 1; ; attempt++ {
+		if opt.Retries > 0 {
+			slog.Info("Checking health", "address", opt.Address, "attempt", attempt, "max_attempts", opt.Retries+1)
+		}
+		ev := newEventEmitter(opt)
+		if collectsResults(opt) {
+			// only the result of the last attempt is written
+			result = newCheckResult(opt)
+			ev.subscribe(result.observe)
+		}
+		if streamsWatchEvents(opt) {
+			if opt.ResetSeq {
+				seq.reset()
+			}
+			ev.subscribe(seq.handler(opt))
+		}
+		err = checkHealth(ctx, opt, ev)
+		if err != nil {
+			ev.emit(Event{Type: EventFailed, Err: err})
+		}
+		if err == nil || attempt > opt.Retries || !isRetryable(err, retryOn) {
+			break
+		}
+		delay := retryDelay(opt.RetryInterval, opt.RetryJitter)
+		slog.Warn("Health check failed, retrying",
+			"address", opt.Address,
+			"attempt", attempt,
+			"retry_interval", delay,
+			"error", err,
+		)
+		if !sleepContext(ctx, delay) {
+			break
+		}
+	}
+	if result != nil && !(result.Status == "" && errors.Is(ctx.Err(), context.Canceled)) {
+		// a check interrupted before the response is not a result
+		o := opt
+		if streamsWatchEvents(opt) && err == nil {
+			// the received statuses are already written as JSON lines
+			o.Output, o.jsonFile = "text", nil
+		}
+		writeResult(o, result, err)
+	}
+	return err
+}
+
+// keepaliveParams returns the keepalive parameters of the flags, and false if none of them is set.
+// A zero time or timeout keeps the gRPC default (no pings, and 20s respectively).
+func keepaliveParams(opt CLIClient) (keepalive.ClientParameters, bool) {
+	if opt.KeepaliveTime == 0 && opt.KeepaliveTimeout == 0 && !opt.KeepaliveNoStream {
+		return keepalive.ClientParameters{}, false
+	}
+	return keepalive.ClientParameters{
+		Time:                opt.KeepaliveTime,
+		Timeout:             opt.KeepaliveTimeout,
+		PermitWithoutStream: opt.KeepaliveNoStream,
+	}, true
+}
+
+// defaultRetryOn are the codes retried when --retry-on is not set (for library use)
+var defaultRetryOn = []codes.Code{codes.Unavailable, codes.DeadlineExceeded}
+
+// retryCodes parses the code names of --retry-on, and returns the default codes if none is given
+func retryCodes(names []string) ([]codes.Code, error) {
+	if len(names) == 0 {
+		return defaultRetryOn, nil
+	}
+	retryOn := make([]codes.Code, 0, len(names))
+	for _, name := range names {
+		c, err := parseCode(name)
+		if err != nil {
+			return nil, err
+		}
+		retryOn = append(retryOn, c)
+	}
+	return retryOn, nil
+}
+
+// isRetryable reports whether a failed check may succeed on a retry:
+// a service that is not serving yet, or a failure with one of the retryOn codes.
+// A service unknown to the server is not retried.
+func isRetryable(err error, retryOn []codes.Code) bool {
+	var nse *notServingError
+	if errors.As(err, &nse) {
+		return nse.status == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	return slices.Contains(retryOn, errorCode(err))
+}
+
+// retryDelay returns the delay before a retry with the jitter applied to the interval:
+// full jitter picks a delay from 0 to the interval, and equal jitter from half of the interval to the interval.
+func retryDelay(interval time.Duration, jitter string) time.Duration {
+	if interval <= 0 {
+		return interval
+	}
+	switch jitter {
+	case "full":
+		return time.Duration(rand.Int64N(int64(interval) + 1))
+	case "equal":
+		half := interval / 2
+		return half + time.Duration(rand.Int64N(int64(interval-half)+1))
+	}
+	return interval
+}
+
+// sleepContext waits for d on clk, and returns false if the context is done before that
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-clk.After(d):
+		return true
+	}
+}
+
+// checkHealth checks a single target and emits the lifecycle events
+func checkHealth(ctx context.Context, opt CLIClient, ev *eventEmitter) error {
 	dialOpts := []grpc.DialOption{}
 	var target string
-	
-	// Check if address is Unix Domain Socket
-	if isUnixSocket(opt.Address) {
-		socketPath := parseUnixSocketPath(opt.Address)
-		target = "unix:" + socketPath
-		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
-			return net.Dial("unix", socketPath)
-		}))
+	var network, address string
+	if hasResolverScheme(opt.Resolvers, opt.Address) {
+		// Leave the target as is for the custom resolver
+		address = opt.Address
+	} else {
+		var err error
+		network, address, err = resolveTarget(opt.Address)
+		if err != nil {
+			return err
+		}
+	}
+
+	var dialer dialFunc
+	if network == "unix" {
+		target = "unix:" + address
+		dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", address)
+		}
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		slog.Info("Using Unix Domain Socket connection", "socket_path", socketPath)
+		slog.Info("Using Unix Domain Socket connection", "socket_path", address)
 	} else {
-		target = opt.Address
+		target = address
 		if opt.TLS {
-			var creds credentials.TransportCredentials
+			tlsConfig, err := clientTLSConfig(opt)
+			if err != nil {
+				return err
+			}
 			if opt.Insecure {
-				creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
 				slog.Info("Using TLS with insecure mode (certificate verification disabled)")
 			} else {
-				creds = credentials.NewTLS(nil)
 				slog.Info("Using TLS with certificate verification")
 			}
-			dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
+			if opt.CAFile != "" {
+				slog.Info("Using custom CA bundle", "ca_file", opt.CAFile)
+			}
+			if opt.Cert != "" {
+				slog.Info("Using client certificate for mutual TLS", "cert", opt.Cert)
+			}
+			dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 		} else {
 			dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 			slog.Info("Using plaintext connection")
 		}
+		if opt.Relay != "" {
+			slog.Info("Using relay connection", "relay", opt.Relay)
+			dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+				return dialRelay(ctx, opt.Relay, addr)
+			}
+		} else if opt.ContextDialer != nil {
+			dialer = opt.ContextDialer
+		}
+	}
+	if opt.MaxRedials > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		dialer = limitRedials(dialer, opt.MaxRedials, cancel)
+	}
+	if dialer != nil {
+		dialOpts = append(dialOpts, grpc.WithContextDialer(dialer))
+	}
+	if len(opt.Resolvers) > 0 {
+		dialOpts = append(dialOpts, grpc.WithResolvers(opt.Resolvers...))
+	}
+	if opt.DisableServiceConfig {
+		dialOpts = append(dialOpts, grpc.WithDisableServiceConfig())
+	}
+	if opt.UserAgent != "" {
+		dialOpts = append(dialOpts, grpc.WithUserAgent(opt.UserAgent))
+	}
+	if kp, ok := keepaliveParams(opt); ok {
+		slog.Info("Using keepalive",
+			"time", kp.Time,
+			"timeout", kp.Timeout,
+			"permit_without_stream", kp.PermitWithoutStream,
+		)
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(kp))
+	}
+	if opt.InjectClientLatency > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(latencyInjectionUnaryInterceptor(opt.InjectClientLatency)))
+	}
+	var sizes *payloadSizeHandler
+	if opt.ShowWireSize {
+		sizes = &payloadSizeHandler{}
+		dialOpts = append(dialOpts, grpc.WithStatsHandler(sizes))
+	}
+	if ev.enabled() {
+		dialOpts = append(dialOpts, grpc.WithStatsHandler(ev.statsHandler()))
 	}
 
 	conn, err := grpc.NewClient(target, dialOpts...)
@@ -58,11 +412,60 @@ func runClient(ctx context.Context, opt CLIClient) error {
 		return fmt.Errorf("failed to connect to gRPC server: %w", err)
 	}
 	defer conn.Close()
+	if opt.ShowTarget {
+		slog.Info("Resolved gRPC target",
+			"address", opt.Address,
+			"target", conn.Target(),
+			"canonical_target", conn.CanonicalTarget(),
+		)
+	}
+
+	// The connection is established lazily by the first RPC or Connect
+	ev.emit(Event{Type: EventDialStarted})
+	// --timeout bounds the connection of --connect-only, the List request and the Check request,
+	// but not the streaming modes that run until interrupted
+	checkCtx := ctx
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
+	if opt.ConnectOnly {
+		err := waitForReady(checkCtx, conn, opt.Address)
+		if err != nil && ctx.Err() == nil && errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("connection timed out after %s: %w", opt.Timeout, err)
+		}
+		return err
+	}
 
 	client := grpc_health_v1.NewHealthClient(conn)
+	if opt.PathPrefix != "" {
+		client, err = newPrefixedHealthClient(conn, opt.PathPrefix)
+		if err != nil {
+			return err
+		}
+		slog.Info("Using path prefix for the health service", "path_prefix", opt.PathPrefix)
+	}
+	md, err := outgoingMetadata(opt)
+	if err != nil {
+		return err
+	}
+	if len(md) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, md)
+		checkCtx = metadata.NewOutgoingContext(checkCtx, md)
+	}
 	req := &grpc_health_v1.HealthCheckRequest{
 		Service: opt.Service,
 	}
+	if opt.snapshot != nil {
+		return listHealth(checkCtx, client, opt.snapshot)
+	}
+	if opt.ExpectTransition != "" {
+		return expectTransition(ctx, client, req, opt.ExpectTransition, opt.Within, ev)
+	}
+	if opt.Watch {
+		return watchHealth(ctx, client, req, ev)
+	}
 	slog.Info("Sending health check request",
 		"address", opt.Address,
 		"service", opt.Service,
@@ -71,37 +474,285 @@ func runClient(ctx context.Context, opt CLIClient) error {
 	callerOpts := []grpc.CallOption{
 		grpc.Peer(&pe),
 	}
+	if opt.Compression == gzip.Name {
+		callerOpts = append(callerOpts, grpc.UseCompressor(gzip.Name))
+	}
+	if opt.MaxRedials > 0 {
+		// Keep redialing until the cap is reached instead of failing on the first attempt
+		callerOpts = append(callerOpts, grpc.WaitForReady(true))
+	}
 	start := time.Now()
-	resp, err := client.Check(ctx, req, callerOpts...)
+	resp, err := client.Check(checkCtx, req, callerOpts...)
+	if err != nil && opt.Compression == gzip.Name && status.Code(err) == codes.Unimplemented {
+		slog.Warn("Compression is unavailable on the server, retrying without compression",
+			"compression", opt.Compression,
+			"error", err,
+		)
+		resp, err = client.Check(checkCtx, req, grpc.Peer(&pe))
+	}
+	if err != nil && ctx.Err() != nil && errors.Is(context.Cause(ctx), errTooManyRedials) {
+		err = context.Cause(ctx)
+	}
+	if err != nil && opt.RawStatus && opt.Output != "json" && opt.Output != "junit" {
+		// the other outputs have the raw status in the result
+		if werr := writeRawStatus(opt.stdout(), err); werr != nil {
+			slog.Warn("Failed to print the raw gRPC status", "error", werr)
+		}
+	}
 	if err != nil {
+		// A plaintext server answering a TLS handshake is left to --auto-tls to fall back
+		if opt.RefusedAsUnhealthy && status.Code(err) == codes.Unavailable && !(opt.TLS && isPlaintextServerError(err)) {
+			slog.Warn("Server is unavailable, treating as not serving",
+				"address", opt.Address,
+				"error", err,
+			)
+			if opt.StatusCodeOnly {
+				fmt.Fprintln(opt.stdout(), int32(grpc_health_v1.HealthCheckResponse_NOT_SERVING))
+			}
+			return &notServingError{service: opt.Service, status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}
+		}
+		if ctx.Err() == nil && errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("health check timed out after %s: %w", opt.Timeout, err)
+		}
 		return fmt.Errorf("health check request failed: %w", err)
 	}
-	duration := time.Since(start)
+	received := time.Now()
+	duration := received.Sub(start)
+	var peerCert *x509.Certificate
+	if tlsInfo, ok := pe.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		peerCert = tlsInfo.State.PeerCertificates[0]
+	}
+	ev.emit(Event{
+		Type:            EventResponseReceived,
+		Time:            received,
+		Status:          resp.GetStatus(),
+		Duration:        duration,
+		Peer:            pe.Addr,
+		PeerCertificate: peerCert,
+	})
+	if opt.StatusCodeOnly {
+		fmt.Fprintln(opt.stdout(), int32(resp.GetStatus()))
+	}
 	status := resp.GetStatus().String()
-	slog.Info("Received health check response",
+	attrs := []any{
 		"service", opt.Service,
 		"status", status,
 		"duration", duration,
 		"peer", pe.Addr.String(),
-	)
+	}
+	if opt.DeployID != "" {
+		attrs = append(attrs, "deploy_id", opt.DeployID)
+	}
+	if opt.Timestamps {
+		attrs = append(attrs,
+			"request_sent", start.Format(time.RFC3339Nano),
+			"response_received", received.Format(time.RFC3339Nano),
+		)
+	}
+	slog.Info("Received health check response", attrs...)
+	if opt.ShowWireSize {
+		slog.Info("Message wire size",
+			"request_length", sizes.outLength.Load(),
+			"request_wire_length", sizes.outWireLength.Load(),
+			"response_length", sizes.inLength.Load(),
+			"response_wire_length", sizes.inWireLength.Load(),
+		)
+	}
+	if opt.WarnLatency > 0 && duration > opt.WarnLatency {
+		slog.Warn("Health check latency exceeded the warning threshold",
+			"service", opt.Service,
+			"duration", duration,
+			"threshold", opt.WarnLatency,
+		)
+	}
+
+	if peerCert != nil {
+		slog.Info("Peer certificate information",
+			"subject", peerCert.Subject,
+			"issuer", peerCert.Issuer,
+			"notBefore", peerCert.NotBefore,
+			"notAfter", peerCert.NotAfter,
+		)
+	}
+
+	if resp.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING {
+		return nil
+	}
+	return &notServingError{service: opt.Service, status: resp.GetStatus()}
+}
+
+// runServicesClient checks each of the services and fails if any of them is not serving.
+// --timeout is the budget of all the services, so a slow check leaves less time to the rest,
+// and the services left when it runs out are failed without being checked.
+// The streaming modes are not bounded by --timeout, so they have no budget.
+func runServicesClient(ctx context.Context, opt CLIClient) error {
+	budget := opt.Timeout > 0 && !opt.Watch && opt.ExpectTransition == "" && opt.Interval == 0
+	if budget {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
+	var errs []error
+	for _, service := range opt.Services {
+		o := opt
+		o.Services = nil
+		o.Service = service
+		if budget && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err := fmt.Errorf("skipped service %s: the timeout budget of %s is exhausted: %w", service, opt.Timeout, context.DeadlineExceeded)
+			if collectsResults(o) {
+				writeResult(o, newCheckResult(o), err)
+			}
+			slog.Error("Service is not healthy", "service", service, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		if err := runClient(ctx, o); err != nil {
+			slog.Error("Service is not healthy", "service", service, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d services are not healthy: %w", len(errs), len(opt.Services), errors.Join(errs...))
+	}
+	return nil
+}
+
+// dialFunc is a function to dial the address for grpc.WithContextDialer
+type dialFunc func(context.Context, string) (net.Conn, error)
 
-	if pe.AuthInfo != nil {
-		if tlsInfo, ok := pe.AuthInfo.(credentials.TLSInfo); ok {
-			if len(tlsInfo.State.PeerCertificates) > 0 {
-				cert := tlsInfo.State.PeerCertificates[0]
-				slog.Info("Peer certificate information",
-					"subject", cert.Subject,
-					"issuer", cert.Issuer,
-					"notBefore", cert.NotBefore,
-					"notAfter", cert.NotAfter,
-				)
+// errTooManyRedials is the cancel cause when the connection attempts reach --max-redials.
+// It carries codes.Unavailable so that it is classified as a connection failure.
+var errTooManyRedials = status.Error(codes.Unavailable, "too many failed connection attempts")
+
+// limitRedials wraps the dialer to cancel the context after max failed connection attempts.
+// If dial is nil, it dials the address over TCP.
+func limitRedials(dial dialFunc, max int, cancel context.CancelCauseFunc) dialFunc {
+	if dial == nil {
+		dial = func(ctx context.Context, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	}
+	var failures atomic.Int32
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, addr)
+		if err != nil {
+			n := int(failures.Add(1))
+			slog.Warn("Connection attempt failed", "attempt", n, "max", max, "error", err)
+			if n >= max {
+				cancel(fmt.Errorf("gave up after %d attempts: %w: %w", n, errTooManyRedials, err))
 			}
 		}
+		return conn, err
 	}
+}
 
-	if resp.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING {
+// waitForReady establishes the connection and waits until it becomes ready
+func waitForReady(ctx context.Context, conn *grpc.ClientConn, address string) error {
+	start := time.Now()
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			slog.Info("Connection established",
+				"address", address,
+				"duration", time.Since(start),
+			)
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return status.Errorf(codes.Unavailable, "failed to connect to %s: connection is %s", address, state)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("failed to connect to %s: %w", address, ctx.Err())
+		}
+	}
+}
+
+// runAutoTLSClient tries TLS first and retries with plaintext if the server does not speak TLS
+func runAutoTLSClient(ctx context.Context, opt CLIClient) error {
+	opt.AutoTLS = false
+	opt.TLS = true
+	// Write the result of the TLS attempt only if it does not fall back to plaintext
+	first := opt
+	var result *checkResult
+	if collectsResults(opt) {
+		first = opt.withoutResults()
+		result = newCheckResult(opt)
+		first.OnEvent = func(ev Event) {
+			result.observe(ev)
+			if opt.OnEvent != nil {
+				opt.OnEvent(ev)
+			}
+		}
+	}
+	err := runClient(ctx, first)
+	if result != nil && !isPlaintextServerError(err) {
+		writeResult(opt, result, err)
+	}
+	if err == nil {
+		slog.Info("Connected with TLS", "address", opt.Address)
 		return nil
 	}
-	return fmt.Errorf("service %s is not serving: %s", opt.Service, status)
+	if !isPlaintextServerError(err) {
+		return err
+	}
+	slog.Warn("Server does not speak TLS, retrying with plaintext",
+		"address", opt.Address,
+		"error", err,
+	)
+	opt.TLS = false
+	if err := runClient(ctx, opt); err != nil {
+		return err
+	}
+	slog.Info("Connected with plaintext", "address", opt.Address)
+	return nil
+}
+
+// isPlaintextServerError checks if the TLS handshake failed because the server does not speak TLS.
+// Other handshake failures, such as an untrusted certificate, are real misconfigurations and not matched.
+func isPlaintextServerError(err error) bool {
+	if status.Code(err) != codes.Unavailable {
+		return false
+	}
+	return strings.Contains(status.Convert(err).Message(), "first record does not look like a TLS handshake")
+}
+
+// hasResolverScheme checks if the address uses the scheme of one of the given resolvers
+func hasResolverScheme(resolvers []resolver.Builder, address string) bool {
+	for _, r := range resolvers {
+		if strings.HasPrefix(address, r.Scheme()+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadSizeHandler is a stats.Handler that records the size of the last sent and received messages
+type payloadSizeHandler struct {
+	outLength     atomic.Int64
+	outWireLength atomic.Int64
+	inLength      atomic.Int64
+	inWireLength  atomic.Int64
+}
+
+func (h *payloadSizeHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *payloadSizeHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	switch p := s.(type) {
+	case *stats.OutPayload:
+		h.outLength.Store(int64(p.Length))
+		h.outWireLength.Store(int64(p.WireLength))
+	case *stats.InPayload:
+		h.inLength.Store(int64(p.Length))
+		h.inWireLength.Store(int64(p.WireLength))
+	}
+}
+
+func (h *payloadSizeHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
 }
 
+func (h *payloadSizeHandler) HandleConn(context.Context, stats.ConnStats) {}
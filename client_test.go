@@ -1,11 +1,19 @@
 package grpchealth
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,7 +23,12 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestRunClient(t *testing.T) {
@@ -160,18 +173,570 @@ func TestRunClientTLS(t *testing.T) {
 	}
 }
 
+func TestRunClientAutoTLS(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	tests := []struct {
+		name               string
+		opts               []grpc.ServerOption
+		refusedAsUnhealthy bool
+	}{
+		{
+			name: "TLS server",
+			opts: []grpc.ServerOption{grpc.Creds(credentials.NewTLS(&tls.Config{
+				Certificates: []tls.Certificate{cert},
+			}))},
+		},
+		{
+			name: "plaintext server",
+		},
+		{
+			name:               "plaintext server with refused as unhealthy",
+			refusedAsUnhealthy: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", ":0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			defer lis.Close()
+
+			s := grpc.NewServer(tt.opts...)
+			healthServer := health.NewServer()
+			healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+			grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+			go func() {
+				if err := s.Serve(lis); err != nil {
+					t.Logf("Server stopped: %v", err)
+				}
+			}()
+			defer s.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			opt := CLIClient{
+				Address:            lis.Addr().String(),
+				AutoTLS:            true,
+				Insecure:           true,
+				RefusedAsUnhealthy: tt.refusedAsUnhealthy,
+			}
+			if err := runClient(ctx, opt); err != nil {
+				t.Errorf("runClient() with auto TLS failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunClientAutoTLSUntrustedCertificate(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	var buf bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The self-signed certificate is not trusted without --insecure or --ca-file
+	err = runClient(ctx, CLIClient{Address: lis.Addr().String(), AutoTLS: true})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "x509") {
+		t.Errorf("Expected the certificate verification error, got %v", err)
+	}
+	if strings.Contains(buf.String(), "retrying with plaintext") {
+		t.Errorf("Expected no fallback to plaintext, got logs: %s", buf.String())
+	}
+}
+
 func TestRunClientConnectionFailure(t *testing.T) {
 	opt := CLIClient{
 		Address: "localhost:99999", // Non-existent port
 		Service: "",
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	err := runClient(ctx, opt)
+	if err == nil {
+		t.Error("Expected connection failure, got nil error")
+	}
+}
+
+func TestRunClientRefusedAsUnhealthy(t *testing.T) {
+	// Get an address with no server listening
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	opt := CLIClient{
+		Address:            address,
+		RefusedAsUnhealthy: true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err = runClient(ctx, opt)
+	if err == nil {
+		t.Fatal("Expected not serving error, got nil")
+	}
+	if !strings.Contains(err.Error(), "is not serving") {
+		t.Errorf("Expected not serving error, got %v", err)
+	}
+}
+
+func TestRunClientCustomResolver(t *testing.T) {
+	// Setup an in-memory server
+	lis := bufconn.Listen(1024 * 1024)
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Route test:///foo to the bufconn without DNS
+	r := manual.NewBuilderWithScheme("test")
+	r.InitialState(resolver.State{
+		Addresses: []resolver.Address{{Addr: "bufnet"}},
+	})
+
+	opt := CLIClient{
+		Address:              "test:///foo",
+		DisableServiceConfig: true,
+		Resolvers:            []resolver.Builder{r},
+		ContextDialer: func(ctx context.Context, addr string) (net.Conn, error) {
+			if addr != "bufnet" {
+				t.Errorf("Unexpected dial address: %s", addr)
+			}
+			return lis.DialContext(ctx)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := runClient(ctx, opt); err != nil {
+		t.Errorf("Custom resolver client failed: %v", err)
+	}
+}
+
+func TestRunClientCompressionFallback(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	// Reject the first request as if the compressor is not installed on the server
+	var calls atomic.Int32
+	s := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if calls.Add(1) == 1 {
+			return nil, status.Error(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding \"gzip\"")
+		}
+		return handler(ctx, req)
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	opt := CLIClient{
+		Address:     lis.Addr().String(),
+		Compression: "gzip",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := runClient(ctx, opt); err != nil {
+		t.Errorf("Expected fallback without compression to succeed, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 calls, got %d", got)
+	}
+}
+
+func TestRunClientWarnLatency(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Capture log output
+	var buf bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	opt := CLIClient{
+		Address:     lis.Addr().String(),
+		WarnLatency: time.Nanosecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := runClient(ctx, opt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "level=WARN") {
+		t.Errorf("Expected latency warning, got logs: %s", buf.String())
+	}
+}
+
+func TestRunClientShowTarget(t *testing.T) {
+	// Capture log output
+	var buf bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	opt := CLIClient{
+		Address:    "localhost:99999", // Non-existent port
+		ShowTarget: true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	runClient(ctx, opt)
+	if !strings.Contains(buf.String(), "canonical_target=dns:///localhost:99999") {
+		t.Errorf("Expected canonical target in logs, got: %s", buf.String())
+	}
+}
+
+func TestRunClientShowWireSize(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Capture log output
+	var buf bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	opt := CLIClient{
+		Address:      lis.Addr().String(),
+		ShowWireSize: true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := runClient(ctx, opt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// HealthCheckResponse{Status: SERVING} is 2 bytes + 5 bytes gRPC message header
+	if !strings.Contains(buf.String(), "response_wire_length=7") {
+		t.Errorf("Expected response wire length in logs, got: %s", buf.String())
+	}
+}
+
+func TestRunClientUserAgent(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	uaCh := make(chan string, 1)
+	s := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		uaCh <- strings.Join(md.Get("user-agent"), ",")
+		return handler(ctx, req)
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	opt := CLIClient{
+		Address:   lis.Addr().String(),
+		UserAgent: "grpchealth/test",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := runClient(ctx, opt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ua := <-uaCh; !strings.HasPrefix(ua, "grpchealth/test ") {
+		t.Errorf("Expected user-agent to start with grpchealth/test, got %q", ua)
+	}
+}
+
+func TestRunClientStatusCodeOnly(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		service string
+		want    string
+	}{
+		{service: "", want: "1\n"},
+		{service: "down", want: "2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			// Capture stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("Failed to create pipe: %v", err)
+			}
+			originalStdout := os.Stdout
+			os.Stdout = w
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			runClient(ctx, CLIClient{
+				Address:        lis.Addr().String(),
+				Service:        tt.service,
+				StatusCodeOnly: true,
+			})
+
+			os.Stdout = originalStdout
+			w.Close()
+			out, _ := io.ReadAll(r)
+			if string(out) != tt.want {
+				t.Errorf("Expected %q on stdout, got %q", tt.want, string(out))
+			}
+		})
+	}
+}
+
+func TestRunClientConnectOnly(t *testing.T) {
+	// Server without the health service
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Get an address with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{
+			name:    "reachable server",
+			address: lis.Addr().String(),
+		},
+		{
+			name:    "unreachable server",
+			address: closedAddress,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := runClient(ctx, CLIClient{Address: tt.address, ConnectOnly: true})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunClientConnectOnlyTimeout(t *testing.T) {
+	// A listener that never completes the TLS handshake, like a blackholed address
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := runClient(ctx, opt)
+	start := time.Now()
+	err = runClient(ctx, CLIClient{
+		Address:     lis.Addr().String(),
+		TLS:         true,
+		Insecure:    true,
+		ConnectOnly: true,
+		Timeout:     200 * time.Millisecond,
+	})
 	if err == nil {
-		t.Error("Expected connection failure, got nil error")
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected --timeout to bound the connection, took %v", elapsed)
+	}
+	if got := failureReason(err); got != "timeout" {
+		t.Errorf("failureReason() = %q, want timeout (error: %v)", got, err)
+	}
+}
+
+func TestRunClientDeadline(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name     string
+		deadline time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "future deadline",
+			deadline: time.Now().Add(2 * time.Second),
+		},
+		{
+			name:     "past deadline",
+			deadline: time.Now().Add(-time.Second),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runClient(context.Background(), CLIClient{
+				Address:  lis.Addr().String(),
+				Deadline: tt.deadline,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
 	}
 }
 
@@ -417,3 +982,406 @@ func (m *mockHealthServer) Check(ctx context.Context, req *grpc_health_v1.Health
 		}, nil
 	}
 }
+
+func TestRunClientMaxRedials(t *testing.T) {
+	// Get an address with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := closed.Addr().String()
+	closed.Close()
+
+	var attempts atomic.Int32
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = runClient(ctx, CLIClient{
+		Address:    address,
+		MaxRedials: 2,
+		ContextDialer: func(ctx context.Context, addr string) (net.Conn, error) {
+			attempts.Add(1)
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+	})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if !errors.Is(err, errTooManyRedials) {
+		t.Errorf("runClient() error = %v, want errTooManyRedials", err)
+	}
+	if got := failureReason(err); got != "connect_failed" {
+		t.Errorf("failureReason() = %q, want %q", got, "connect_failed")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("connection attempts = %d, want 2", n)
+	}
+}
+
+// blockingHealthServer does not respond until the request is canceled
+type blockingHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (blockingHealthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRunClientTimeout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, blockingHealthServer{})
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err = runClient(ctx, CLIClient{
+		Address: lis.Addr().String(),
+		Timeout: 100 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("runClient() took %s, want about the timeout", elapsed)
+	}
+	if !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("runClient() error = %v, want a timed out message", err)
+	}
+	if got := failureReason(err); got != "timeout" {
+		t.Errorf("failureReason() = %q, want %q", got, "timeout")
+	}
+}
+
+func TestRunClientMultipleServices(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("orders", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name     string
+		services []string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "no services checks the default", services: nil, want: []string{""}},
+		{name: "single service", services: []string{"users"}, want: []string{"users"}},
+		{name: "all serving", services: []string{"", "users"}, want: []string{"", "users"}},
+		{name: "one not serving", services: []string{"orders", "users"}, want: []string{"orders", "users"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			var checked []string
+			err := runClient(ctx, CLIClient{
+				Address:  lis.Addr().String(),
+				Services: tt.services,
+				OnEvent: func(ev Event) {
+					if ev.Type == EventResponseReceived {
+						checked = append(checked, ev.Service)
+					}
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && ExitCode(err) != 1 {
+				t.Errorf("ExitCode() = %d, want 1", ExitCode(err))
+			}
+			if !slices.Equal(checked, tt.want) {
+				t.Errorf("checked services = %q, want %q", checked, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunClientMultipleServicesTimeoutBudget(t *testing.T) {
+	address := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Each check takes 300ms, so the budget of 500ms covers the first one only
+	var buf bytes.Buffer
+	start := time.Now()
+	err := runClient(ctx, CLIClient{
+		Address:             address,
+		Services:            []string{"", "", ""},
+		Timeout:             500 * time.Millisecond,
+		InjectClientLatency: 300 * time.Millisecond,
+		Output:              "json",
+		Stdout:              &buf,
+	})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the checks to take about the budget, took %v", elapsed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a result for each service, got: %s", buf.String())
+	}
+	var reasons []string
+	for _, line := range lines {
+		var r checkResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Result is not a JSON object: %v: %s", err, line)
+		}
+		reasons = append(reasons, r.Reason)
+	}
+	if want := []string{"ok", "timeout", "timeout"}; !slices.Equal(reasons, want) {
+		t.Errorf("reasons = %q, want %q", reasons, want)
+	}
+	if !strings.Contains(lines[2], "skipped service") {
+		t.Errorf("Expected the last service to be skipped, got: %s", lines[2])
+	}
+}
+
+func TestRunClientRetries(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("warming", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Get an address with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	refused := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name         string
+		address      string
+		service      string
+		retries      int
+		retryOn      []string
+		setServing   bool
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "no retry on success", retries: 3, wantAttempts: 1},
+		{name: "retries until serving", service: "warming", retries: 5, setServing: true, wantAttempts: 3},
+		{name: "gives up after retries", service: "warming", retries: 2, wantAttempts: 3, wantErr: true},
+		{name: "no retry on unknown service", service: "unknown", retries: 3, wantAttempts: 1, wantErr: true},
+		{name: "retries connection refused by default", address: refused, retries: 2, wantAttempts: 3, wantErr: true},
+		{name: "no retry on a code not in --retry-on", address: refused, retries: 2, retryOn: []string{"DeadlineExceeded"}, wantAttempts: 1, wantErr: true},
+		{name: "retries a code of --retry-on", service: "unknown", retries: 2, retryOn: []string{"NotFound"}, wantAttempts: 3, wantErr: true},
+		{name: "retries not serving regardless of --retry-on", service: "warming", retries: 2, retryOn: []string{"NotFound"}, wantAttempts: 3, wantErr: true},
+		{name: "invalid --retry-on", retries: 2, retryOn: []string{"Unavailible"}, wantAttempts: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthServer.SetServingStatus("warming", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			address := tt.address
+			if address == "" {
+				address = lis.Addr().String()
+			}
+			var attempts int
+			err := runClient(ctx, CLIClient{
+				Address:       address,
+				Service:       tt.service,
+				Retries:       tt.retries,
+				RetryOn:       tt.retryOn,
+				RetryInterval: 10 * time.Millisecond,
+				OnEvent: func(ev Event) {
+					if ev.Type != EventDialStarted {
+						return
+					}
+					attempts++
+					if tt.setServing && attempts == 3 {
+						healthServer.SetServingStatus("warming", grpc_health_v1.HealthCheckResponse_SERVING)
+					}
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	interval := 100 * time.Millisecond
+	tests := []struct {
+		jitter string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{jitter: "none", min: interval, max: interval},
+		{jitter: "", min: interval, max: interval},
+		{jitter: "full", min: 0, max: interval},
+		{jitter: "equal", min: interval / 2, max: interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.jitter, func(t *testing.T) {
+			seen := make(map[time.Duration]bool)
+			for range 1000 {
+				d := retryDelay(interval, tt.jitter)
+				if d < tt.min || d > tt.max {
+					t.Fatalf("retryDelay(%s, %q) = %s, want between %s and %s", interval, tt.jitter, d, tt.min, tt.max)
+				}
+				seen[d] = true
+			}
+			if tt.min != tt.max && len(seen) < 2 {
+				t.Errorf("retryDelay(%s, %q) is not randomized", interval, tt.jitter)
+			}
+		})
+	}
+	if d := retryDelay(0, "full"); d != 0 {
+		t.Errorf("retryDelay(0, full) = %s, want 0", d)
+	}
+}
+
+func TestRunClientRetriesCanceled(t *testing.T) {
+	// Get an address with no server listening
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	var buf bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = runClient(ctx, CLIClient{
+		Address:       address,
+		Retries:       100,
+		RetryInterval: 100 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("runClient() took %s after the context was done", elapsed)
+	}
+	if !strings.Contains(buf.String(), "attempt=1 ") || !strings.Contains(buf.String(), "attempt=2 ") {
+		t.Errorf("attempts are not logged: %s", buf.String())
+	}
+}
+
+func TestKeepaliveParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		opt    CLIClient
+		want   keepalive.ClientParameters
+		wantOK bool
+	}{
+		{name: "not set", opt: CLIClient{}},
+		{
+			name:   "time and timeout",
+			opt:    CLIClient{KeepaliveTime: 30 * time.Second, KeepaliveTimeout: 5 * time.Second},
+			want:   keepalive.ClientParameters{Time: 30 * time.Second, Timeout: 5 * time.Second},
+			wantOK: true,
+		},
+		{
+			name:   "permit without stream only",
+			opt:    CLIClient{KeepaliveNoStream: true},
+			want:   keepalive.ClientParameters{PermitWithoutStream: true},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := keepaliveParams(tt.opt)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("keepaliveParams() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRunClientKeepalive(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	// The server allows the client pings
+	s := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             time.Second,
+		PermitWithoutStream: true,
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = runClient(ctx, CLIClient{
+		Address:           lis.Addr().String(),
+		KeepaliveTime:     10 * time.Second,
+		KeepaliveTimeout:  time.Second,
+		KeepaliveNoStream: true,
+	})
+	if err != nil {
+		t.Errorf("runClient() error = %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package grpchealth
+
+import "time"
+
+// clock is the time source of the poll, retry and watch loops
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) ticker
+}
+
+// ticker is the subset of time.Ticker used by the loops
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// clk is replaceable for testing the scheduling without real sleeps
+var clk clock = realClock{}
+
+// realClock is the clock of the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
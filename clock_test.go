@@ -0,0 +1,283 @@
+package grpchealth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakeClock is a clock whose time advances only by Advance
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// waits receives the duration of each After and NewTicker call
+	waits chan time.Duration
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		waits: make(chan time.Duration, 100),
+	}
+}
+
+// useFakeClock replaces clk with a fake clock until the end of the test
+func useFakeClock(t *testing.T) *fakeClock {
+	t.Helper()
+	c := newFakeClock()
+	original := clk
+	clk = c
+	t.Cleanup(func() { clk = original })
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.add(d, 0).c
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	return c.add(d, d)
+}
+
+func (c *fakeClock) add(d, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	c.waits <- d
+	return t
+}
+
+// Advance moves the time forward and fires the timers that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var pending []*fakeTimer
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+			// a slow receiver drops ticks like time.Ticker
+		}
+		if t.period > 0 {
+			t.at = c.now.Add(t.period)
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+}
+
+// wait returns the duration of the next After or NewTicker call
+func (c *fakeClock) wait(t *testing.T, ctx context.Context) time.Duration {
+	t.Helper()
+	select {
+	case d := <-c.waits:
+		return d
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the clock to be waited on")
+		return 0
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timers = slices.DeleteFunc(c.timers, func(x *fakeTimer) bool { return x == t })
+}
+
+func TestRunClientRetriesClock(t *testing.T) {
+	fake := useFakeClock(t)
+
+	// Get an address with no server listening
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runClient(ctx, CLIClient{
+			Address:       address,
+			Retries:       3,
+			RetryInterval: time.Hour,
+		})
+	}()
+
+	// The retries wait an hour each on the clock, not in real time
+	for i := range 3 {
+		if d := fake.wait(t, ctx); d != time.Hour {
+			t.Errorf("retry #%d waited %s, want %s", i+1, d, time.Hour)
+		}
+		fake.Advance(time.Hour)
+	}
+	select {
+	case err := <-errCh:
+		if failureReason(err) != "connect_failed" {
+			t.Errorf("runClient() error = %v, want connect_failed", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("runClient() did not return after the retries")
+	}
+}
+
+func TestRunClientIntervalClock(t *testing.T) {
+	fake := useFakeClock(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	statuses := make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pollCtx, stop := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runClient(pollCtx, CLIClient{
+			Address:  lis.Addr().String(),
+			Interval: time.Hour,
+			OnEvent: func(ev Event) {
+				if ev.Type == EventResponseReceived {
+					statuses <- ev.Status
+				}
+			},
+		})
+	}()
+
+	if d := fake.wait(t, ctx); d != time.Hour {
+		t.Fatalf("ticker interval = %s, want %s", d, time.Hour)
+	}
+	// Each tick of the clock checks once more
+	for i := range 3 {
+		select {
+		case <-statuses:
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for check #%d", i+1)
+		}
+		fake.Advance(time.Hour)
+	}
+	select {
+	case <-statuses:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the check after the last tick")
+	}
+	// No check without a tick
+	select {
+	case st := <-statuses:
+		t.Errorf("unexpected check without a tick: %s", st)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stop()
+	if err := <-errCh; err != nil {
+		t.Errorf("runClient() error = %v, want nil", err)
+	}
+}
+
+func TestRunClientExpectTransitionClock(t *testing.T) {
+	fake := useFakeClock(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("stuck", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	received := make(chan struct{}, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runClient(ctx, CLIClient{
+			Address:          lis.Addr().String(),
+			Service:          "stuck",
+			ExpectTransition: "NOT_SERVING->SERVING",
+			Within:           time.Hour,
+			OnEvent: func(ev Event) {
+				if ev.Type == EventResponseReceived {
+					received <- struct{}{}
+				}
+			},
+		})
+	}()
+
+	if d := fake.wait(t, ctx); d != time.Hour {
+		t.Fatalf("transition window = %s, want %s", d, time.Hour)
+	}
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the first status")
+	}
+	// The window ends by the clock, not in real time
+	fake.Advance(time.Hour)
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "within 1h0m0s") {
+			t.Errorf("runClient() error = %v, want the transition timed out within 1h", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("runClient() did not return at the end of the window")
+	}
+}
@@ -14,7 +14,7 @@ func main() {
 	defer stop()
 	if err := run(ctx); err != nil {
 		slog.Error(err.Error())
-		os.Exit(1)
+		os.Exit(app.ExitCode(err))
 	}
 }
 
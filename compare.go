@@ -0,0 +1,126 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// lookupHost is replaceable for testing
+var lookupHost = net.DefaultResolver.LookupHost
+
+// peerResult is the health check result of a single backend
+type peerResult struct {
+	address     string
+	status      grpc_health_v1.HealthCheckResponse_ServingStatus
+	fingerprint string
+	err         error
+}
+
+// runComparePeersClient checks every backend the host resolves to and reports
+// inconsistencies between them
+func runComparePeersClient(ctx context.Context, opt CLIClient) error {
+	if opt.Relay != "" {
+		return fmt.Errorf("--compare-peers cannot be used with --relay")
+	}
+	host, port, err := net.SplitHostPort(opt.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address for --compare-peers: %w", err)
+	}
+	addrs, err := lookupHost(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to lookup %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no addresses found for %s", host)
+	}
+	slog.Info("Resolved peers", "host", host, "peers", len(addrs))
+
+	results := make([]*peerResult, 0, len(addrs))
+	for _, addr := range addrs {
+		r := &peerResult{address: net.JoinHostPort(addr, port)}
+		o := opt
+		o.ComparePeers = false
+		o.backend = r.address
+		// Keep the host name as the target for TLS verification, but dial this backend
+		o.ContextDialer = func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", r.address)
+		}
+		o.OnEvent = func(ev Event) {
+			if ev.Type == EventResponseReceived {
+				r.status = ev.Status
+				if ev.PeerCertificate != nil {
+					sum := sha256.Sum256(ev.PeerCertificate.Raw)
+					r.fingerprint = hex.EncodeToString(sum[:])
+				}
+			}
+			if opt.OnEvent != nil {
+				opt.OnEvent(ev)
+			}
+		}
+		r.err = runClient(ctx, o)
+		if r.err != nil {
+			slog.Error("Peer is not healthy", "peer", r.address, "error", r.err)
+		} else {
+			slog.Info("Peer is healthy", "peer", r.address)
+		}
+		results = append(results, r)
+	}
+	return comparePeers(results)
+}
+
+// comparePeers summarizes the results and returns an error if the peers are unhealthy or inconsistent
+func comparePeers(results []*peerResult) error {
+	statuses := map[string]int{}
+	fingerprints := map[string]int{}
+	var failed int
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+		if r.status == grpc_health_v1.HealthCheckResponse_UNKNOWN && r.err != nil {
+			statuses["ERROR"]++
+		} else {
+			statuses[r.status.String()]++
+		}
+		if r.fingerprint != "" {
+			fingerprints[r.fingerprint]++
+		}
+	}
+
+	var inconsistencies []string
+	if len(statuses) > 1 {
+		inconsistencies = append(inconsistencies, "status")
+	}
+	if len(fingerprints) > 1 {
+		inconsistencies = append(inconsistencies, "certificate")
+	}
+	attrs := []any{
+		"peers", len(results),
+		"statuses", statuses,
+	}
+	if len(fingerprints) > 0 {
+		attrs = append(attrs, "certificates", len(fingerprints))
+	}
+	if len(inconsistencies) > 0 {
+		slices.Sort(inconsistencies)
+		slog.Warn("Peers are inconsistent", append(attrs, "differ_in", inconsistencies)...)
+	} else {
+		slog.Info("Peers are consistent", attrs...)
+	}
+
+	switch {
+	case failed > 0:
+		return fmt.Errorf("%d of %d peers are not healthy", failed, len(results))
+	case len(inconsistencies) > 0:
+		return fmt.Errorf("peers are inconsistent in %v", inconsistencies)
+	}
+	return nil
+}
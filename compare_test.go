@@ -0,0 +1,173 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startPeer starts a health server on ip:port and returns the listening port
+func startPeer(t *testing.T, ip string, port int, st grpc_health_v1.HealthCheckResponse_ServingStatus, opts ...grpc.ServerOption) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer(opts...)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", st)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func tlsServerOption(t *testing.T) grpc.ServerOption {
+	t.Helper()
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+	return grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+}
+
+func TestRunComparePeersClient(t *testing.T) {
+	serving := grpc_health_v1.HealthCheckResponse_SERVING
+	notServing := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T) int
+		tls     bool
+		wantErr bool
+	}{
+		{
+			name: "consistent",
+			setup: func(t *testing.T) int {
+				port := startPeer(t, "127.0.0.1", 0, serving)
+				startPeer(t, "127.0.0.2", port, serving)
+				return port
+			},
+		},
+		{
+			name: "one peer not serving",
+			setup: func(t *testing.T) int {
+				port := startPeer(t, "127.0.0.1", 0, serving)
+				startPeer(t, "127.0.0.2", port, notServing)
+				return port
+			},
+			wantErr: true,
+		},
+		{
+			name: "one peer down",
+			setup: func(t *testing.T) int {
+				return startPeer(t, "127.0.0.1", 0, serving)
+			},
+			wantErr: true,
+		},
+		{
+			name: "different certificates",
+			setup: func(t *testing.T) int {
+				port := startPeer(t, "127.0.0.1", 0, serving, tlsServerOption(t))
+				startPeer(t, "127.0.0.2", port, serving, tlsServerOption(t))
+				return port
+			},
+			tls:     true,
+			wantErr: true,
+		},
+	}
+
+	originalLookupHost := lookupHost
+	defer func() { lookupHost = originalLookupHost }()
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.1", "127.0.0.2"}, nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := tt.setup(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := runClient(ctx, CLIClient{
+				Address:      net.JoinHostPort("localhost", strconv.Itoa(port)),
+				ComparePeers: true,
+				TLS:          tt.tls,
+				Insecure:     tt.tls,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunComparePeersClientResults(t *testing.T) {
+	originalLookupHost := lookupHost
+	defer func() { lookupHost = originalLookupHost }()
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.1", "127.0.0.2"}, nil
+	}
+
+	notServing := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	port := startPeer(t, "127.0.0.1", 0, notServing)
+	startPeer(t, "127.0.0.2", port, notServing)
+	address := net.JoinHostPort("localhost", strconv.Itoa(port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	err := runClient(ctx, CLIClient{
+		Address:      address,
+		ComparePeers: true,
+		Output:       "json",
+		Summary:      true,
+		Stdout:       &buf,
+	})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected two results and a summary, got: %s", buf.String())
+	}
+	backends := map[string]bool{}
+	for _, line := range lines[:2] {
+		var r checkResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Result is not a JSON object: %v: %s", err, line)
+		}
+		if r.Address != address {
+			t.Errorf("address = %q, want %q", r.Address, address)
+		}
+		backends[r.Backend] = true
+	}
+	for _, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+		if backend := net.JoinHostPort(ip, strconv.Itoa(port)); !backends[backend] {
+			t.Errorf("Expected a result for backend %s, got %v", backend, backends)
+		}
+	}
+
+	var summary runSummary
+	if err := json.Unmarshal([]byte(lines[2]), &summary); err != nil {
+		t.Fatalf("Summary is not a JSON object: %v: %s", err, lines[2])
+	}
+	if summary.Total != 2 || len(summary.Unhealthy) != 2 {
+		t.Errorf("total = %d, unhealthy = %+v, want 2 unhealthy peers", summary.Total, summary.Unhealthy)
+	}
+}
@@ -0,0 +1,145 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serverConfig is the --config file of the server
+//
+//	services:
+//	  - name: users
+//	    status: SERVING
+type serverConfig struct {
+	Services []serviceConfig `json:"services" yaml:"services"`
+}
+
+type serviceConfig struct {
+	Name   string `json:"name" yaml:"name"`
+	Status string `json:"status" yaml:"status"`
+}
+
+// loadServerConfig reads the config file. A .json file is parsed as JSON, and others as YAML.
+func loadServerConfig(path string) (*serverConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config file: %w", err)
+	}
+	var c serverConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	} else {
+		err = yaml.UnmarshalWithOptions(b, &c, yaml.Strict())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the config file %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// statuses returns the initial statuses of the services in the config
+func (c *serverConfig) statuses() (map[string]grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	statuses := make(map[string]grpc_health_v1.HealthCheckResponse_ServingStatus, len(c.Services))
+	for i, svc := range c.Services {
+		if svc.Name == "" {
+			return nil, fmt.Errorf("services[%d]: name is required", i)
+		}
+		if _, ok := statuses[svc.Name]; ok {
+			return nil, fmt.Errorf("services[%d]: duplicate service %q", i, svc.Name)
+		}
+		st, err := parseServingStatus(svc.Status)
+		if err != nil {
+			return nil, fmt.Errorf("services[%d] %q: %w", i, svc.Name, err)
+		}
+		statuses[svc.Name] = st
+	}
+	return statuses, nil
+}
+
+// serviceStatuses returns the statuses of the named services in the config file,
+// overridden by the --service flags
+func serviceStatuses(opt CLIServer) (map[string]grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	statuses := map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{}
+	if opt.Config != "" {
+		c, err := loadServerConfig(opt.Config)
+		if err != nil {
+			return nil, err
+		}
+		s, err := c.statuses()
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", opt.Config, err)
+		}
+		maps.Copy(statuses, s)
+	}
+	s, err := parseServiceStatuses(opt.Services)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(statuses, s)
+	return statuses, nil
+}
+
+// reloadConfigOnSignal re-reads the config file on SIGHUP and applies the changes of the statuses
+// until ctx is done. An invalid config is logged and the current statuses are kept.
+func reloadConfigOnSignal(ctx context.Context, opt CLIServer, healthServer *health.Server) {
+	sigs := reloadSignals()
+	if len(sigs) == 0 {
+		slog.Warn("Reloading the config file on a signal is not supported on this platform")
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	current := opt.statuses
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			slog.Info("Reloading the config file", "config", opt.Config, "signal", sig.String())
+			statuses, err := serviceStatuses(opt)
+			if err != nil {
+				slog.Error("Failed to reload the config file, keeping the current statuses", "error", err)
+				continue
+			}
+			applyStatusChanges(healthServer, current, statuses)
+			current = statuses
+		}
+	}
+}
+
+// applyStatusChanges sets the statuses that differ from the current ones.
+// The health server cannot unregister a service, so a removed service is reported as NOT_SERVING.
+func applyStatusChanges(healthServer *health.Server, current, next map[string]grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	for _, name := range slices.Sorted(maps.Keys(next)) {
+		st := next[name]
+		if old, ok := current[name]; ok && old == st {
+			continue
+		}
+		healthServer.SetServingStatus(name, st)
+		slog.Info("Service status updated", "service", name, "status", st.String())
+	}
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		if _, ok := next[name]; ok {
+			continue
+		}
+		healthServer.SetServingStatus(name, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		slog.Warn("Service removed from the config, reporting NOT_SERVING until restart", "service", name)
+	}
+}
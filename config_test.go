@@ -0,0 +1,100 @@
+package grpchealth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write the config file: %v", err)
+	}
+	return path
+}
+
+func TestServiceStatusesConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		services []string
+		want     map[string]grpc_health_v1.HealthCheckResponse_ServingStatus
+		wantErr  bool
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `services:
+  - name: users
+    status: SERVING
+  - name: orders
+    status: not_serving
+`,
+			want: map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{
+				"users":  grpc_health_v1.HealthCheckResponse_SERVING,
+				"orders": grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+			},
+		},
+		{
+			name:    "json",
+			file:    "config.json",
+			content: `{"services": [{"name": "users", "status": "SERVING"}]}`,
+			want: map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{
+				"users": grpc_health_v1.HealthCheckResponse_SERVING,
+			},
+		},
+		{
+			name:     "flags override the config",
+			file:     "config.yml",
+			content:  "services:\n  - name: users\n    status: SERVING\n",
+			services: []string{"users=NOT_SERVING", "orders=SERVING"},
+			want: map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{
+				"users":  grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+				"orders": grpc_health_v1.HealthCheckResponse_SERVING,
+			},
+		},
+		{name: "invalid status", file: "config.yaml", content: "services:\n  - name: users\n    status: SERVNG\n", wantErr: true},
+		{name: "missing name", file: "config.yaml", content: "services:\n  - status: SERVING\n", wantErr: true},
+		{name: "duplicate service", file: "config.yaml", content: "services:\n  - {name: users, status: SERVING}\n  - {name: users, status: NOT_SERVING}\n", wantErr: true},
+		{name: "unknown field in yaml", file: "config.yaml", content: "services:\n  - name: users\n    state: SERVING\n", wantErr: true},
+		{name: "unknown field in json", file: "config.json", content: `{"service": []}`, wantErr: true},
+		{name: "broken json", file: "config.json", content: `{"services": [`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := serviceStatuses(CLIServer{
+				Config:   writeConfigFile(t, tt.file, tt.content),
+				Services: tt.services,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for name, st := range tt.want {
+				if got[name] != st {
+					t.Errorf("status of %q = %v, want %v", name, got[name], st)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceStatusesMissingConfig(t *testing.T) {
+	if _, err := serviceStatuses(CLIServer{Config: filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
@@ -0,0 +1,135 @@
+package grpchealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // register the standard error details for JSON
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// notServingError is returned when the service responds with a status other than SERVING
+type notServingError struct {
+	service string
+	status  grpc_health_v1.HealthCheckResponse_ServingStatus
+}
+
+func (e *notServingError) Error() string {
+	return fmt.Sprintf("service %s is not serving: %s", e.service, e.status)
+}
+
+// failureReason classifies the result of a health check into a stable token
+func failureReason(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var nse *notServingError
+	if errors.As(err, &nse) {
+		if nse.status == grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN {
+			return "not_found"
+		}
+		return "not_serving"
+	}
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return "connect_failed"
+	case codes.NotFound:
+		return "not_found"
+	case codes.DeadlineExceeded:
+		return "timeout"
+	case codes.Canceled:
+		return "canceled"
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "error"
+}
+
+// errorCode returns the gRPC code of err.
+// The context errors without a gRPC status are classified as DeadlineExceeded and Canceled.
+func errorCode(err error) codes.Code {
+	if st, ok := grpcStatus(err); ok {
+		return st.Code()
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	}
+	return codes.Unknown
+}
+
+// parseCode parses a gRPC code name in either form of Unavailable or UNAVAILABLE
+func parseCode(name string) (codes.Code, error) {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if normalize(c.String()) == normalize(strings.TrimSpace(name)) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown gRPC code %q", name)
+}
+
+// exitCodes maps the failure reasons to the process exit codes
+var exitCodes = map[string]int{
+	"ok":             0,
+	"not_serving":    1,
+	"connect_failed": 2,
+	"not_found":      3,
+	"timeout":        4,
+}
+
+// ExitCode returns the process exit code for the error returned by Run.
+// Errors other than the health check results exit with 1.
+func ExitCode(err error) int {
+	if code, ok := exitCodes[failureReason(err)]; ok {
+		return code
+	}
+	return 1
+}
+
+// writeRawStatus writes the full gRPC status proto (code, message, details) of err as JSON
+func writeRawStatus(w io.Writer, err error) error {
+	b, err := rawStatus(err)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// grpcStatus returns the gRPC status carried by err, and false for the errors without one
+func grpcStatus(err error) (*status.Status, bool) {
+	// status.FromError would prepend the messages of the wrapping errors
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return nil, false
+	}
+	return se.GRPCStatus(), true
+}
+
+// rawStatus returns the full gRPC status proto (code, message, details) of err as JSON
+func rawStatus(err error) ([]byte, error) {
+	st, ok := grpcStatus(err)
+	if !ok {
+		return nil, fmt.Errorf("not a gRPC status error: %w", err)
+	}
+	b, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gRPC status: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,181 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "success",
+			err:  nil,
+			want: "ok",
+		},
+		{
+			name: "not serving",
+			err:  &notServingError{status: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+			want: "not_serving",
+		},
+		{
+			name: "service unknown",
+			err:  &notServingError{status: grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN},
+			want: "not_found",
+		},
+		{
+			name: "unavailable",
+			err:  fmt.Errorf("health check request failed: %w", status.Error(codes.Unavailable, "connection refused")),
+			want: "connect_failed",
+		},
+		{
+			name: "not found",
+			err:  fmt.Errorf("health check request failed: %w", status.Error(codes.NotFound, "unknown service")),
+			want: "not_found",
+		},
+		{
+			name: "deadline exceeded status",
+			err:  status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			want: "timeout",
+		},
+		{
+			name: "context deadline exceeded",
+			err:  fmt.Errorf("failed: %w", context.DeadlineExceeded),
+			want: "timeout",
+		},
+		{
+			name: "other error",
+			err:  errors.New("something went wrong"),
+			want: "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureReason(tt.err); got != tt.want {
+				t.Errorf("failureReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    codes.Code
+		wantErr bool
+	}{
+		{name: "Unavailable", want: codes.Unavailable},
+		{name: "DeadlineExceeded", want: codes.DeadlineExceeded},
+		{name: "DEADLINE_EXCEEDED", want: codes.DeadlineExceeded},
+		{name: " permissiondenied ", want: codes.PermissionDenied},
+		{name: "OK", want: codes.OK},
+		{name: "Unavailible", wantErr: true},
+		{name: "14", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCode(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCode(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCode(%q) = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "status", err: status.Error(codes.Unavailable, "connection refused"), want: codes.Unavailable},
+		{name: "wrapped status", err: fmt.Errorf("health check timed out: %w", status.Error(codes.DeadlineExceeded, "deadline")), want: codes.DeadlineExceeded},
+		{name: "context deadline", err: fmt.Errorf("failed to connect: %w", context.DeadlineExceeded), want: codes.DeadlineExceeded},
+		{name: "context canceled", err: context.Canceled, want: codes.Canceled},
+		{name: "other", err: errors.New("boom"), want: codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.err); got != tt.want {
+				t.Errorf("errorCode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "serving", err: nil, want: 0},
+		{name: "not serving", err: &notServingError{status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, want: 1},
+		{name: "connection failure", err: status.Error(codes.Unavailable, "connection refused"), want: 2},
+		{name: "not found", err: status.Error(codes.NotFound, "unknown service"), want: 3},
+		{name: "service unknown", err: &notServingError{status: grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN}, want: 3},
+		{name: "timeout", err: fmt.Errorf("health check timed out after 10s: %w", status.Error(codes.DeadlineExceeded, "deadline exceeded")), want: 4},
+		{name: "canceled", err: context.Canceled, want: 1},
+		{name: "other error", err: errors.New("something went wrong"), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteRawStatus(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "backend is draining").WithDetails(&errdetails.ErrorInfo{
+		Reason: "DRAINING",
+		Domain: "example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add details: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeRawStatus(&buf, fmt.Errorf("health check request failed: %w", st.Err())); err != nil {
+		t.Fatalf("writeRawStatus() error = %v", err)
+	}
+	var got struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Details []struct {
+			Type   string `json:"@type"`
+			Reason string `json:"reason"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not JSON: %v: %s", err, buf.String())
+	}
+	if got.Code != int(codes.Unavailable) || got.Message != "backend is draining" {
+		t.Errorf("code, message = %d, %q", got.Code, got.Message)
+	}
+	if len(got.Details) != 1 || got.Details[0].Type != "type.googleapis.com/google.rpc.ErrorInfo" || got.Details[0].Reason != "DRAINING" {
+		t.Errorf("details = %+v", got.Details)
+	}
+
+	if err := writeRawStatus(&buf, errors.New("plain error")); err == nil {
+		t.Error("writeRawStatus() expected error for a non-status error")
+	}
+}
@@ -0,0 +1,113 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"time"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/stats"
+)
+
+// EventType is a step in the lifecycle of a health check
+type EventType string
+
+const (
+	EventDialStarted      EventType = "dial_started"
+	EventConnected        EventType = "connected"
+	EventTLSHandshakeDone EventType = "tls_handshake_done"
+	EventRequestSent      EventType = "request_sent"
+	EventResponseReceived EventType = "response_received"
+	EventFailed           EventType = "failed"
+)
+
+// Event is emitted to CLIClient.OnEvent for each step of a health check
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Address string
+	Service string
+	// Status is set for EventResponseReceived
+	Status grpc_health_v1.HealthCheckResponse_ServingStatus
+	// Duration is the time taken by the request for EventResponseReceived
+	Duration time.Duration
+	// Peer is the address of the server for EventResponseReceived
+	Peer net.Addr
+	// PeerCertificate is the leaf certificate of the server for EventResponseReceived over TLS
+	PeerCertificate *x509.Certificate
+	// Err is set for EventFailed
+	Err error
+}
+
+// eventEmitter sends events of a single target to the handlers
+type eventEmitter struct {
+	opt      CLIClient
+	handlers []func(Event)
+}
+
+func newEventEmitter(opt CLIClient) *eventEmitter {
+	e := &eventEmitter{opt: opt}
+	if opt.OnEvent != nil {
+		e.subscribe(opt.OnEvent)
+	}
+	return e
+}
+
+// subscribe adds a handler to receive the events
+func (e *eventEmitter) subscribe(fn func(Event)) {
+	e.handlers = append(e.handlers, fn)
+}
+
+// enabled reports whether any handler receives the events
+func (e *eventEmitter) enabled() bool {
+	return len(e.handlers) > 0
+}
+
+func (e *eventEmitter) emit(ev Event) {
+	if !e.enabled() {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Address = e.opt.Address
+	ev.Service = e.opt.Service
+	for _, fn := range e.handlers {
+		fn(ev)
+	}
+}
+
+// statsHandler returns a stats.Handler that emits the connection and request events
+func (e *eventEmitter) statsHandler() stats.Handler {
+	return &eventStatsHandler{emitter: e}
+}
+
+type eventStatsHandler struct {
+	emitter *eventEmitter
+}
+
+func (h *eventStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *eventStatsHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if p, ok := s.(*stats.OutPayload); ok && p.IsClient() {
+		h.emitter.emit(Event{Type: EventRequestSent})
+	}
+}
+
+func (h *eventStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *eventStatsHandler) HandleConn(_ context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnBegin); !ok {
+		return
+	}
+	// The transport reports the connection after the credentials handshake is done
+	h.emitter.emit(Event{Type: EventConnected})
+	if h.emitter.opt.TLS {
+		h.emitter.emit(Event{Type: EventTLSHandshakeDone})
+	}
+}
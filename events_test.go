@@ -0,0 +1,86 @@
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRunClientEvents(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name    string
+		service string
+		want    []EventType
+	}{
+		{
+			name: "serving",
+			want: []EventType{EventDialStarted, EventConnected, EventRequestSent, EventResponseReceived},
+		},
+		{
+			name:    "not serving",
+			service: "down",
+			want:    []EventType{EventDialStarted, EventConnected, EventRequestSent, EventResponseReceived, EventFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var mu sync.Mutex
+			var events []Event
+			runClient(ctx, CLIClient{
+				Address: lis.Addr().String(),
+				Service: tt.service,
+				OnEvent: func(ev Event) {
+					mu.Lock()
+					defer mu.Unlock()
+					events = append(events, ev)
+				},
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			var got []EventType
+			for _, ev := range events {
+				got = append(got, ev.Type)
+				if ev.Address != lis.Addr().String() {
+					t.Errorf("event %s address = %q, want %q", ev.Type, ev.Address, lis.Addr().String())
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+			last := events[len(events)-1]
+			if tt.service == "down" && last.Err == nil {
+				t.Error("failed event has no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package grpchealth
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// statusFlipper flips the default service to NOT_SERVING after serving a number of
+// successful checks, and back to SERVING after a cooldown
+type statusFlipper struct {
+	healthServer *health.Server
+	after        int
+	cooldown     time.Duration
+
+	mu      sync.Mutex
+	count   int
+	flipped bool
+}
+
+func newStatusFlipper(healthServer *health.Server, after int, cooldown time.Duration) *statusFlipper {
+	return &statusFlipper{
+		healthServer: healthServer,
+		after:        after,
+		cooldown:     cooldown,
+	}
+}
+
+// unaryInterceptor counts successful checks for the default service
+func (f *statusFlipper) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil || info.FullMethod != grpc_health_v1.Health_Check_FullMethodName {
+		return resp, err
+	}
+	if r, ok := req.(*grpc_health_v1.HealthCheckRequest); !ok || r.GetService() != "" {
+		return resp, err
+	}
+	if r, ok := resp.(*grpc_health_v1.HealthCheckResponse); ok && r.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING {
+		f.observe()
+	}
+	return resp, err
+}
+
+func (f *statusFlipper) observe() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.flipped {
+		return
+	}
+	f.count++
+	if f.count < f.after {
+		return
+	}
+	f.flipped = true
+	f.healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	slog.Info("Flipped default service to NOT_SERVING",
+		"checks", f.count,
+		"cooldown", f.cooldown,
+	)
+	time.AfterFunc(f.cooldown, f.reset)
+}
+
+func (f *statusFlipper) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count = 0
+	f.flipped = false
+	f.healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	slog.Info("Flipped default service back to SERVING")
+}
+
+// flipStatusOnSignal sets the status of the default service on the status signals
+// (SIGUSR1 for NOT_SERVING, SIGUSR2 for SERVING) until ctx is done
+func flipStatusOnSignal(ctx context.Context, healthServer *health.Server) {
+	statuses := statusSignals()
+	if len(statuses) == 0 {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, slices.Collect(maps.Keys(statuses))...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			to := statuses[sig]
+			var from grpc_health_v1.HealthCheckResponse_ServingStatus
+			if resp, err := healthServer.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err == nil {
+				from = resp.GetStatus()
+			}
+			healthServer.SetServingStatus("", to)
+			slog.Warn("Default service status set by signal",
+				"signal", sig.String(),
+				"from", from.String(),
+				"to", to.String(),
+			)
+		}
+	}
+}
@@ -7,8 +7,12 @@ toolchain go1.24.5
 require (
 	github.com/alecthomas/kong v1.12.1
 	github.com/fujiwara/sloghandler v0.0.5
+	github.com/goccy/go-yaml v1.19.2
+	github.com/miekg/pkcs11 v1.1.2
 	golang.org/x/sys v0.34.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a
 	google.golang.org/grpc v1.74.2
+	google.golang.org/protobuf v1.36.6
 )
 
 require (
@@ -17,6 +21,4 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 )
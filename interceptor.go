@@ -0,0 +1,98 @@
+package grpchealth
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoveryUnaryInterceptor converts a panic in the handler into an Internal error
+func recoveryUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverPanic(info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoveryStreamInterceptor converts a panic in the handler into an Internal error
+func recoveryStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverPanic(info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recoverPanic(method string, r any) error {
+	slog.Error("Recovered from panic in handler",
+		"method", method,
+		"panic", r,
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// slowRequestDetector warns when a request takes longer than the threshold.
+// A trivial health handler being slow indicates scheduler starvation or GC pauses in the process.
+type slowRequestDetector struct {
+	threshold time.Duration
+	count     atomic.Int64
+}
+
+func newSlowRequestDetector(threshold time.Duration) *slowRequestDetector {
+	return &slowRequestDetector{threshold: threshold}
+}
+
+func (d *slowRequestDetector) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	if elapsed := time.Since(start); elapsed > d.threshold {
+		slog.Warn("Slow request detected",
+			"method", info.FullMethod,
+			"duration", elapsed,
+			"threshold", d.threshold,
+			"slow_requests", d.count.Add(1),
+		)
+	}
+	return resp, err
+}
+
+// slowRequests returns the number of slow requests detected so far
+func (d *slowRequestDetector) slowRequests() int64 {
+	return d.count.Load()
+}
+
+// deadlineLoggingUnaryInterceptor logs the remaining deadline of the request set by the client,
+// and warns about requests without a deadline
+func deadlineLoggingUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		slog.Info("Incoming request deadline",
+			"method", info.FullMethod,
+			"remaining", time.Until(deadline),
+		)
+	} else {
+		slog.Warn("Incoming request has no deadline", "method", info.FullMethod)
+	}
+	return handler(ctx, req)
+}
+
+// latencyInjectionUnaryInterceptor delays each call on the client by d to simulate a slow probe.
+// The delay is interrupted by the deadline of the call.
+func latencyInjectionUnaryInterceptor(d time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		slog.Warn("Injecting client latency", "method", method, "latency", d)
+		if !sleepContext(ctx, d) {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,155 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: grpc_health_v1.Health_Check_FullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	}
+
+	resp, err := recoveryUnaryInterceptor(context.Background(), &grpc_health_v1.HealthCheckRequest{}, info, handler)
+	if resp != nil {
+		t.Errorf("Expected nil response, got %v", resp)
+	}
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal error, got %v", err)
+	}
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: grpc_health_v1.Health_Watch_FullMethodName}
+	handler := func(srv any, ss grpc.ServerStream) error {
+		panic("boom")
+	}
+
+	err := recoveryStreamInterceptor(nil, nil, info, handler)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal error, got %v", err)
+	}
+}
+
+func TestSlowRequestDetector(t *testing.T) {
+	detector := newSlowRequestDetector(50 * time.Millisecond)
+	info := &grpc.UnaryServerInfo{FullMethod: grpc_health_v1.Health_Check_FullMethodName}
+	req := &grpc_health_v1.HealthCheckRequest{}
+
+	tests := []struct {
+		name  string
+		delay time.Duration
+		want  int64
+	}{
+		{name: "fast request", delay: 0, want: 0},
+		{name: "slow request", delay: 100 * time.Millisecond, want: 1},
+		{name: "another fast request", delay: 0, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(ctx context.Context, req any) (any, error) {
+				time.Sleep(tt.delay)
+				return &grpc_health_v1.HealthCheckResponse{}, nil
+			}
+			resp, err := detector.unaryInterceptor(context.Background(), req, info, handler)
+			if err != nil || resp == nil {
+				t.Errorf("Expected the handler result to pass through, got %v, %v", resp, err)
+			}
+			if got := detector.slowRequests(); got != tt.want {
+				t.Errorf("slowRequests() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadlineLoggingUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: grpc_health_v1.Health_Check_FullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return &grpc_health_v1.HealthCheckResponse{}, nil
+	}
+
+	withDeadline, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{name: "with deadline", ctx: withDeadline, want: `level=INFO msg="Incoming request deadline"`},
+		{name: "without deadline", ctx: context.Background(), want: `level=WARN msg="Incoming request has no deadline"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture log output
+			var buf bytes.Buffer
+			originalLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(originalLogger)
+
+			resp, err := deadlineLoggingUnaryInterceptor(tt.ctx, &grpc_health_v1.HealthCheckRequest{}, info, handler)
+			if err != nil || resp == nil {
+				t.Errorf("Expected the handler result to pass through, got %v, %v", resp, err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("Expected %s in logs, got: %s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestRunClientInjectClientLatency(t *testing.T) {
+	address := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	tests := []struct {
+		name    string
+		latency time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "within timeout", latency: 200 * time.Millisecond, timeout: 2 * time.Second},
+		{name: "exceeds timeout", latency: 2 * time.Second, timeout: 200 * time.Millisecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			err := runClient(ctx, CLIClient{
+				Address:             address,
+				Timeout:             tt.timeout,
+				InjectClientLatency: tt.latency,
+			})
+			elapsed := time.Since(start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), "timed out") {
+					t.Errorf("Expected a timeout error, got %v", err)
+				}
+				if elapsed >= tt.latency {
+					t.Errorf("Expected the latency to be interrupted by the timeout, took %v", elapsed)
+				}
+				return
+			}
+			if elapsed < tt.latency {
+				t.Errorf("Expected the check to take at least %v, took %v", tt.latency, elapsed)
+			}
+		})
+	}
+}
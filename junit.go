@@ -0,0 +1,105 @@
+package grpchealth
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitTestSuite struct {
+	XMLName    xml.Name         `xml:"testsuite"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	TestCases  []junitTestCase  `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Failure    *junitFailure    `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSeconds formats milliseconds as seconds for the time attributes
+func junitSeconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
+
+// writeJUnit writes the results as a JUnit XML report with one testcase per target and service.
+// The deploy ID is written as a property of the test suite if set.
+func writeJUnit(w io.Writer, deployID string, results []*checkResult) error {
+	suite := junitTestSuite{
+		Name:  "grpchealth",
+		Tests: len(results),
+	}
+	if deployID != "" {
+		suite.Properties = &junitProperties{
+			Properties: []junitProperty{{Name: "deploy_id", Value: deployID}},
+		}
+	}
+	var total float64
+	for _, res := range results {
+		name := res.Service
+		if name == "" {
+			name = "(default)"
+		}
+		className := res.Address
+		if res.Backend != "" {
+			className += " (" + res.Backend + ")"
+		}
+		tc := junitTestCase{
+			Name:      name,
+			ClassName: className,
+			Time:      junitSeconds(res.DurationMs),
+		}
+		if res.LatencyExceeded {
+			tc.Properties = &junitProperties{
+				Properties: []junitProperty{{Name: "latency_exceeded", Value: "true"}},
+			}
+		}
+		if res.Error != "" {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: res.Error,
+				Type:    res.Reason,
+				Text:    res.Error,
+			}
+			if res.RawStatus != nil {
+				tc.Failure.Text += "\n" + string(res.RawStatus)
+			}
+		}
+		total += res.DurationMs
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
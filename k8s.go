@@ -0,0 +1,182 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// in-cluster configuration, replaceable for testing
+var (
+	k8sTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	k8sCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	k8sAPIServer = func() string {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return ""
+		}
+		return "https://" + net.JoinHostPort(host, port)
+	}
+)
+
+// k8sEndpointSliceList is the subset of the discovery.k8s.io/v1 EndpointSliceList object used to find pods
+type k8sEndpointSliceList struct {
+	Items []k8sEndpointSlice `json:"items"`
+}
+
+type k8sEndpointSlice struct {
+	AddressType string `json:"addressType"`
+	Endpoints   []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+		TargetRef *struct {
+			Name string `json:"name"`
+		} `json:"targetRef"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	} `json:"ports"`
+}
+
+// k8sPod is a pod address backing a Kubernetes Service
+type k8sPod struct {
+	name    string
+	address string
+}
+
+// runK8sClient lists the ready pods of the Kubernetes Service and checks each pod directly.
+// The address is the port number or the port name of the Service endpoints.
+func runK8sClient(ctx context.Context, opt CLIClient) error {
+	namespace, name, ok := strings.Cut(opt.K8sService, "/")
+	if !ok || namespace == "" || name == "" {
+		return fmt.Errorf("invalid --k8s-service %q: must be namespace/name", opt.K8sService)
+	}
+	if strings.Contains(opt.Address, ":") {
+		return fmt.Errorf("invalid address %q with --k8s-service: must be the port number or the port name of the Service, not host:port", opt.Address)
+	}
+	pods, err := lookupK8sPods(ctx, namespace, name, opt.Address, opt.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to lookup pods for %s: %w", opt.K8sService, err)
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("no ready pods found for %s", opt.K8sService)
+	}
+	slog.Info("Resolved Kubernetes Service", "service", opt.K8sService, "pods", len(pods))
+
+	var errs []error
+	for _, pod := range pods {
+		o := opt
+		o.Address = pod.address
+		o.K8sService = ""
+		if err := runClient(ctx, o); err != nil {
+			errs = append(errs, fmt.Errorf("pod %s: %w", pod.name, err))
+			slog.Error("Pod is not healthy", "pod", pod.name, "address", pod.address, "error", err)
+			continue
+		}
+		slog.Info("Pod is healthy", "pod", pod.name, "address", pod.address)
+	}
+	return batchError(opt, errs, len(pods), "pods")
+}
+
+// lookupK8sPods fetches the EndpointSlices of the Service from the Kubernetes API with the in-cluster credentials.
+// The request is bounded by timeout (--timeout), so an unresponsive API server does not hang the check.
+func lookupK8sPods(ctx context.Context, namespace, name, port string, timeout time.Duration) ([]k8sPod, error) {
+	server := k8sAPIServer()
+	if server == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+	token, err := os.ReadFile(k8sTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(k8sCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", k8sCAFile)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		Timeout:   timeout,
+	}
+
+	u := fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s", server, url.PathEscape(namespace),
+		url.Values{"labelSelector": {"kubernetes.io/service-name=" + name}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from Kubernetes API: %s", resp.Status)
+	}
+	var list k8sEndpointSliceList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode endpoint slices: %w", err)
+	}
+	return list.pods(port)
+}
+
+// pods returns the ready pod addresses for the port given by number or name.
+// An endpoint listed in more than one slice is returned once.
+func (l *k8sEndpointSliceList) pods(port string) ([]k8sPod, error) {
+	var pods []k8sPod
+	seen := make(map[string]bool)
+	for _, slice := range l.Items {
+		if slice.AddressType != "IPv4" && slice.AddressType != "IPv6" {
+			continue
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			p = 0
+			for _, sp := range slice.Ports {
+				if sp.Name == port {
+					p = sp.Port
+					break
+				}
+			}
+			if p == 0 {
+				return nil, fmt.Errorf("port %q is not found in the endpoint slices", port)
+			}
+		}
+		for _, ep := range slice.Endpoints {
+			// a nil ready condition means ready
+			if len(ep.Addresses) == 0 || (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) {
+				continue
+			}
+			// the addresses of an endpoint are fungible, so the first one is used
+			address := net.JoinHostPort(ep.Addresses[0], strconv.Itoa(p))
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			name := ep.Addresses[0]
+			if ep.TargetRef != nil {
+				name = ep.TargetRef.Name
+			}
+			pods = append(pods, k8sPod{name: name, address: address})
+		}
+	}
+	return pods, nil
+}
@@ -0,0 +1,163 @@
+package grpchealth
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRunK8sClient(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+	port := lis.Addr().(*net.TCPAddr).Port
+
+	// Get a port with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	endpointSlices := map[string]string{
+		"healthy": fmt.Sprintf(`{"items":[{"addressType":"IPv4",
+			"endpoints":[{"addresses":["127.0.0.1"],"conditions":{"ready":true},"targetRef":{"name":"app-0"}}],
+			"ports":[{"name":"grpc","port":%d}]}]}`, port),
+		"partial": fmt.Sprintf(`{"items":[
+			{"addressType":"IPv4","endpoints":[{"addresses":["127.0.0.1"],"conditions":{"ready":true},"targetRef":{"name":"app-0"}}],"ports":[{"name":"grpc","port":%d}]},
+			{"addressType":"IPv4","endpoints":[{"addresses":["127.0.0.1"],"targetRef":{"name":"app-1"}}],"ports":[{"name":"grpc","port":%d}]}]}`, port, closedPort),
+		"not-ready": fmt.Sprintf(`{"items":[{"addressType":"IPv4",
+			"endpoints":[
+				{"addresses":["127.0.0.1"],"conditions":{"ready":true},"targetRef":{"name":"app-0"}},
+				{"addresses":["127.0.0.2"],"conditions":{"ready":false},"targetRef":{"name":"app-1"}}],
+			"ports":[{"name":"grpc","port":%d}]}]}`, port),
+		"duplicated": fmt.Sprintf(`{"items":[
+			{"addressType":"IPv4","endpoints":[{"addresses":["127.0.0.1"],"targetRef":{"name":"app-0"}}],"ports":[{"name":"grpc","port":%d}]},
+			{"addressType":"IPv4","endpoints":[{"addresses":["127.0.0.1"],"targetRef":{"name":"app-0"}}],"ports":[{"name":"grpc","port":%d}]},
+			{"addressType":"FQDN","endpoints":[{"addresses":["unreachable.invalid"]}],"ports":[{"name":"grpc","port":%d}]}]}`, port, port, closedPort),
+		"empty": `{"items":[]}`,
+	}
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices" {
+			http.NotFound(w, r)
+			return
+		}
+		body, ok := endpointSlices[strings.TrimPrefix(r.URL.Query().Get("labelSelector"), "kubernetes.io/service-name=")]
+		if !ok {
+			// no slices are labeled for an unknown Service
+			body = `{"items":[]}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer api.Close()
+	useK8sAPI(t, api)
+
+	tests := []struct {
+		name    string
+		service string
+		port    string
+		wantErr bool
+	}{
+		{name: "all pods healthy by port name", service: "default/healthy", port: "grpc"},
+		{name: "all pods healthy by port number", service: "default/healthy", port: strconv.Itoa(port)},
+		{name: "one pod down", service: "default/partial", port: "grpc", wantErr: true},
+		{name: "not ready pods are skipped", service: "default/not-ready", port: "grpc"},
+		{name: "endpoints in multiple slices are checked once", service: "default/duplicated", port: "grpc"},
+		{name: "no pods", service: "default/empty", port: "grpc", wantErr: true},
+		{name: "unknown port name", service: "default/healthy", port: "http", wantErr: true},
+		{name: "service not found", service: "default/missing", port: "grpc", wantErr: true},
+		{name: "invalid service", service: "healthy", port: "grpc", wantErr: true},
+		{name: "host:port address", service: "default/healthy", port: net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := runClient(ctx, CLIClient{Address: tt.port, K8sService: tt.service})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// useK8sAPI makes the in-cluster credentials point to the API server until the end of the test
+func useK8sAPI(t *testing.T, api *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: api.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	origToken, origCA, origServer := k8sTokenFile, k8sCAFile, k8sAPIServer
+	k8sTokenFile, k8sCAFile = tokenFile, caFile
+	k8sAPIServer = func() string { return api.URL }
+	t.Cleanup(func() {
+		k8sTokenFile, k8sCAFile, k8sAPIServer = origToken, origCA, origServer
+	})
+}
+
+func TestRunK8sClientAPITimeout(t *testing.T) {
+	// The API server does not respond until the end of the test
+	release := make(chan struct{})
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer api.Close()
+	defer close(release)
+	useK8sAPI(t, api)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := runClient(ctx, CLIClient{Address: "grpc", K8sService: "default/slow", Timeout: 200 * time.Millisecond})
+	if err == nil {
+		t.Fatal("runClient() expected error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("runClient() returned after %s, want about the timeout of 200ms", elapsed)
+	}
+}
@@ -3,6 +3,7 @@ package grpchealth
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -11,29 +12,94 @@ import (
 )
 
 type CLI struct {
-	Server CLIServer `cmd:"" help:"Run gRPC health check server"`
-	Client CLIClient `cmd:"" help:"Run gRPC health check client"`
+	ShowVersion versionFlag `help:"Show the version and exit" name:"version" short:"v"`
+
+	Server   CLIServer   `cmd:"" help:"Run gRPC health check server"`
+	Client   CLIClient   `cmd:"" help:"Run gRPC health check client"`
+	SelfTest CLISelfTest `cmd:"" name:"selftest" help:"Run server and client in memory without opening any ports"`
+	Snapshot CLISnapshot `cmd:"" help:"Save the status of every service known to the server to a JSON file"`
+	Version  CLIVersion  `cmd:"" help:"Show the version, the git commit and the build date"`
 }
 
+// Run runs the command with os.Args
 func Run(ctx context.Context) error {
+	return RunArgs(ctx, os.Args[1:])
+}
+
+// RunArgs runs the command with the given arguments (without the program name)
+func RunArgs(ctx context.Context, args []string) error {
+	var cli CLI
+	parser, err := kong.New(&cli,
+		kong.Vars{"version": buildVersion()},
+		// --help and --version return from RunArgs instead of terminating the process
+		kong.Exit(func(code int) { panic(kongExit{code: code}) }),
+	)
+	if err != nil {
+		return err
+	}
+	k, err := parse(parser, args)
+	if err != nil || k == nil {
+		return err
+	}
+
 	opts := &sloghandler.HandlerOptions{
 		HandlerOptions: slog.HandlerOptions{
 			Level: slog.LevelDebug,
 		},
 		Color: true, // Colorize the output based on log level
 	}
-	handler := sloghandler.NewLogHandler(os.Stdout, opts)
+	var w io.Writer = os.Stdout
+	if writesStdout(cli.Client) {
+		// Keep stdout for the status code or the results, and only errors go to stderr
+		w = os.Stderr
+		opts.Level = slog.LevelError
+	} else if cli.Client.RawStatus {
+		// Keep stdout for the raw status
+		w = os.Stderr
+	}
+	handler := sloghandler.NewLogHandler(w, opts)
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
-	var cli CLI
-	k := kong.Parse(&cli)
 	switch k.Command() {
 	case "server <address>":
 		return runServer(ctx, cli.Server)
 	case "client <address>":
-		return runClient(ctx, cli.Client)
+		err := runClient(ctx, cli.Client)
+		// Always print a machine-readable reason token on stderr
+		fmt.Fprintf(os.Stderr, "REASON=%s\n", failureReason(err))
+		return err
+	case "selftest":
+		return runSelfTest(ctx, cli.SelfTest)
+	case "snapshot <address>":
+		return runSnapshot(ctx, cli.Snapshot)
+	case "version":
+		return runVersion(os.Stdout)
 	default:
 		return fmt.Errorf("unknown command: %s", k.Command())
 	}
 }
+
+// kongExit is the panic value of the exit requested by kong, recovered by parse
+type kongExit struct {
+	code int
+}
+
+// parse parses the arguments, and returns a nil context if kong exited after printing the help or the version
+func parse(parser *kong.Kong, args []string) (k *kong.Context, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		exit, ok := r.(kongExit)
+		if !ok {
+			panic(r)
+		}
+		k = nil
+		if exit.code != 0 {
+			err = fmt.Errorf("exit status %d", exit.code)
+		}
+	}()
+	return parser.Parse(args)
+}
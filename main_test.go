@@ -0,0 +1,102 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "selftest",
+			args: []string{"selftest"},
+		},
+		{
+			name:    "selftest with unknown service",
+			args:    []string{"selftest", "--service", "unknown"},
+			wantErr: true,
+		},
+		{
+			name: "version",
+			args: []string{"version"},
+		},
+		{
+			name: "version flag",
+			args: []string{"--version"},
+		},
+		{
+			name: "help",
+			args: []string{"--help"},
+		},
+		{
+			name: "client help",
+			args: []string{"client", "--help"},
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"unknown"},
+			wantErr: true,
+		},
+		{
+			name:    "client without address",
+			args:    []string{"client"},
+			wantErr: true,
+		},
+	}
+
+	// RunArgs replaces the default logger
+	originalLogger := slog.Default()
+	defer slog.SetDefault(originalLogger)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := RunArgs(ctx, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunArgs(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunVersion(t *testing.T) {
+	originalVersion, originalCommit, originalBuildDate := Version, Commit, BuildDate
+	defer func() {
+		Version, Commit, BuildDate = originalVersion, originalCommit, originalBuildDate
+	}()
+	Version, Commit, BuildDate = "v1.2.3", "abc1234", "2025-01-01T00:00:00Z"
+
+	var buf bytes.Buffer
+	if err := runVersion(&buf); err != nil {
+		t.Fatalf("runVersion() error = %v", err)
+	}
+	want := "grpchealth v1.2.3 (commit: abc1234, built: 2025-01-01T00:00:00Z, "
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("runVersion() = %q, want prefix %q", buf.String(), want)
+	}
+}
+
+func TestBuildVersion(t *testing.T) {
+	// The User-Agent and the version command must report the same version
+	want := "grpchealth " + buildVersion() + " "
+	if got := versionString(); !strings.HasPrefix(got, want) {
+		t.Errorf("versionString() = %q, want prefix %q", got, want)
+	}
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "v1.2.3"
+	if got := buildVersion(); got != "v1.2.3" {
+		t.Errorf("buildVersion() = %q, want %q", got, "v1.2.3")
+	}
+}
@@ -0,0 +1,128 @@
+package grpchealth
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// outgoingMetadata builds the metadata sent with the health check request
+func outgoingMetadata(opt CLIClient) (metadata.MD, error) {
+	md := metadata.MD{}
+	if opt.Tenant != "" {
+		if err := validateMetadataKey(opt.TenantHeader); err != nil {
+			return nil, fmt.Errorf("invalid tenant header: %w", err)
+		}
+		if err := validateMetadataValue(opt.Tenant); err != nil {
+			return nil, fmt.Errorf("invalid tenant: %w", err)
+		}
+		md.Append(opt.TenantHeader, opt.Tenant)
+	}
+	if opt.DeployIDHeader != "" && opt.DeployID != "" {
+		if err := validateMetadataKey(opt.DeployIDHeader); err != nil {
+			return nil, fmt.Errorf("invalid deploy ID header: %w", err)
+		}
+		if err := validateMetadataValue(opt.DeployID); err != nil {
+			return nil, fmt.Errorf("invalid deploy ID: %w", err)
+		}
+		md.Append(opt.DeployIDHeader, opt.DeployID)
+	}
+	for _, h := range opt.Headers {
+		key, value, err := parseHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		md.Append(key, value)
+	}
+	token, err := bearerToken(opt)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		if len(md.Get("authorization")) > 0 {
+			return nil, fmt.Errorf("--token and --header authorization cannot be used together")
+		}
+		md.Set("authorization", "Bearer "+token)
+	}
+	return md, nil
+}
+
+// bearerToken returns the token of --token or --token-file.
+// The token is never included in the logs and the errors.
+func bearerToken(opt CLIClient) (string, error) {
+	var token string
+	switch {
+	case opt.Token != "" && opt.TokenFile != "":
+		return "", fmt.Errorf("--token and --token-file cannot be used together")
+	case opt.Token != "":
+		token = opt.Token
+		slog.Info("Sending bearer token")
+	case opt.TokenFile != "":
+		// read on each check so that a rotated token is used
+		b, err := os.ReadFile(opt.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the token file: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+		if token == "" {
+			return "", fmt.Errorf("token file %s is empty", opt.TokenFile)
+		}
+		slog.Info("Sending bearer token", "token_file", opt.TokenFile)
+	default:
+		return "", nil
+	}
+	for _, c := range token {
+		if c < 0x20 || c > 0x7e {
+			return "", fmt.Errorf("token contains an invalid character")
+		}
+	}
+	return token, nil
+}
+
+// parseHeader parses a key=value pair of the --header flag. The key is case-insensitive.
+func parseHeader(h string) (string, string, error) {
+	key, value, ok := strings.Cut(h, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid header %q: must be in the form key=value", h)
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	if err := validateMetadataKey(key); err != nil {
+		return "", "", fmt.Errorf("invalid header %q: %w", h, err)
+	}
+	if err := validateMetadataValue(value); err != nil {
+		return "", "", fmt.Errorf("invalid header %q: %w", h, err)
+	}
+	return key, value, nil
+}
+
+// validateMetadataKey checks if the key is a valid ASCII metadata key
+func validateMetadataKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty metadata key")
+	}
+	if strings.HasPrefix(key, "grpc-") {
+		return fmt.Errorf("metadata key %q is reserved for gRPC", key)
+	}
+	if strings.HasSuffix(key, "-bin") {
+		return fmt.Errorf("binary metadata key %q is not supported", key)
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("metadata key %q contains invalid character %q", key, c)
+		}
+	}
+	return nil
+}
+
+// validateMetadataValue checks if the value is a valid ASCII metadata value
+func validateMetadataValue(value string) error {
+	for _, c := range value {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Errorf("metadata value %q contains invalid character %q", value, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package grpchealth
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutgoingMetadataTenant(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     CLIClient
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no tenant",
+			opt:  CLIClient{TenantHeader: "x-tenant-id"},
+		},
+		{
+			name: "default tenant header",
+			opt:  CLIClient{Tenant: "acme", TenantHeader: "x-tenant-id"},
+			want: "acme",
+		},
+		{
+			name:    "uppercase tenant header",
+			opt:     CLIClient{Tenant: "acme", TenantHeader: "X-Tenant-ID"},
+			wantErr: true,
+		},
+		{
+			name:    "reserved tenant header",
+			opt:     CLIClient{Tenant: "acme", TenantHeader: "grpc-tenant"},
+			wantErr: true,
+		},
+		{
+			name:    "binary tenant header",
+			opt:     CLIClient{Tenant: "acme", TenantHeader: "x-tenant-bin"},
+			wantErr: true,
+		},
+		{
+			name:    "non-printable tenant",
+			opt:     CLIClient{Tenant: "acme\n", TenantHeader: "x-tenant-id"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := outgoingMetadata(tt.opt)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got metadata %v", md)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := md.Get(tt.opt.TenantHeader)
+			if tt.want == "" {
+				if len(got) != 0 {
+					t.Errorf("Expected no tenant metadata, got %v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Expected tenant %q, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOutgoingMetadataDeployID(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     CLIClient
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not sent without header",
+			opt:  CLIClient{DeployID: "v1.2.3"},
+		},
+		{
+			name: "sent with header",
+			opt:  CLIClient{DeployID: "v1.2.3", DeployIDHeader: "x-deploy-id"},
+			want: []string{"v1.2.3"},
+		},
+		{
+			name: "header without deploy ID",
+			opt:  CLIClient{DeployIDHeader: "x-deploy-id"},
+		},
+		{
+			name:    "invalid header",
+			opt:     CLIClient{DeployID: "v1.2.3", DeployIDHeader: "X-Deploy-ID"},
+			wantErr: true,
+		},
+		{
+			name:    "non-printable deploy ID",
+			opt:     CLIClient{DeployID: "v1.2.3\n", DeployIDHeader: "x-deploy-id"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := outgoingMetadata(tt.opt)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got metadata %v", md)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := md.Get("x-deploy-id"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected deploy ID %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOutgoingMetadataHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:    "single header",
+			headers: []string{"authorization=Bearer abc"},
+			want:    map[string][]string{"authorization": {"Bearer abc"}},
+		},
+		{
+			name:    "repeated key and uppercase key",
+			headers: []string{"x-route=a", "X-Route=b"},
+			want:    map[string][]string{"x-route": {"a", "b"}},
+		},
+		{
+			name:    "value with equals and commas",
+			headers: []string{"x-query=a=1,b=2"},
+			want:    map[string][]string{"x-query": {"a=1,b=2"}},
+		},
+		{
+			name:    "empty value",
+			headers: []string{"x-empty="},
+			want:    map[string][]string{"x-empty": {""}},
+		},
+		{
+			name:    "without equals",
+			headers: []string{"authorization"},
+			wantErr: true,
+		},
+		{
+			name:    "empty key",
+			headers: []string{"=value"},
+			wantErr: true,
+		},
+		{
+			name:    "reserved key",
+			headers: []string{"grpc-timeout=1s"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := outgoingMetadata(CLIClient{Headers: tt.headers})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got metadata %v", md)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(map[string][]string(md), tt.want) {
+				t.Errorf("Expected metadata %v, got %v", tt.want, md)
+			}
+		})
+	}
+}
+
+func TestOutgoingMetadataToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opt     CLIClient
+		want    string
+		wantErr bool
+	}{
+		{name: "no token", opt: CLIClient{}},
+		{name: "token", opt: CLIClient{Token: "flag-secret"}, want: "Bearer flag-secret"},
+		{name: "token file", opt: CLIClient{TokenFile: tokenFile}, want: "Bearer file-secret"},
+		{name: "both token and token file", opt: CLIClient{Token: "flag-secret", TokenFile: tokenFile}, wantErr: true},
+		{name: "missing token file", opt: CLIClient{TokenFile: filepath.Join(dir, "missing")}, wantErr: true},
+		{name: "empty token file", opt: CLIClient{TokenFile: emptyFile}, wantErr: true},
+		{name: "invalid token", opt: CLIClient{Token: "flag-secret\n"}, wantErr: true},
+		{name: "token with authorization header", opt: CLIClient{Token: "flag-secret", Headers: []string{"authorization=Basic x"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			originalLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(originalLogger)
+
+			md, err := outgoingMetadata(tt.opt)
+			if err != nil && strings.Contains(err.Error(), "secret") {
+				t.Errorf("Error contains the token: %v", err)
+			}
+			if strings.Contains(buf.String(), "secret") {
+				t.Errorf("Log contains the token: %s", buf.String())
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got metadata %v", md)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := md.Get("authorization")
+			if tt.want == "" {
+				if len(got) != 0 {
+					t.Errorf("Expected no authorization metadata, got %v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Expected authorization %q, got %v", tt.want, got)
+			}
+			if !strings.Contains(buf.String(), "Sending bearer token") {
+				t.Errorf("Sending the token is not logged: %s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package grpchealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errMTLSNotEnforced is returned when the server accepts a client without a certificate
+var errMTLSNotEnforced = errors.New("server accepted a client without a certificate, mutual TLS is not enforced")
+
+// runExpectMTLSRequiredClient checks the health without presenting a client certificate,
+// and succeeds only if the server rejects the connection in the TLS handshake.
+func runExpectMTLSRequiredClient(ctx context.Context, opt CLIClient) error {
+	opt.ExpectMTLSRequired = false
+	opt.TLS = true
+	opt.Cert, opt.Key = "", ""
+	var requested atomic.Bool
+	opt.certificateRequested = &requested
+	// The result is of the negative test, not of the check
+	check := opt
+	var result *checkResult
+	if collectsResults(opt) {
+		check = opt.withoutResults()
+		result = newCheckResult(opt)
+		check.OnEvent = func(ev Event) {
+			result.observe(ev)
+			if opt.OnEvent != nil {
+				opt.OnEvent(ev)
+			}
+		}
+	}
+	err := verifyMTLSRequired(runClient(ctx, check), requested.Load())
+	if result != nil {
+		writeResult(opt, result, err)
+	}
+	if err == nil {
+		slog.Info("Server rejected the client without a certificate", "address", opt.Address)
+	}
+	return err
+}
+
+// verifyMTLSRequired converts the error of a check without a client certificate
+// into the result of the negative test. requested is whether the server asked for a client certificate.
+func verifyMTLSRequired(err error, requested bool) error {
+	var nse *notServingError
+	if err == nil || errors.As(err, &nse) {
+		// the server responded to the health check
+		return errMTLSNotEnforced
+	}
+	if isClientCertificateRejected(err, requested) {
+		return nil
+	}
+	return fmt.Errorf("could not verify that mutual TLS is required: %w", err)
+}
+
+// clientCertificateAlerts are the TLS alerts of a server rejecting the client certificate.
+// With TLS 1.3 the server verifies the client certificate after the client has finished the handshake,
+// so the alert ("certificate required") is received while reading the server preface.
+// With TLS 1.2 some servers send "bad certificate".
+var clientCertificateAlerts = []string{
+	"remote error: tls: certificate required",
+	"remote error: tls: bad certificate",
+}
+
+// handshakeFailureAlert is the generic alert that Go and OpenSSL send with TLS 1.2 for a missing client certificate,
+// but also for a protocol version or cipher suite mismatch
+const handshakeFailureAlert = "remote error: tls: handshake failure"
+
+// isClientCertificateRejected checks if the server aborted the TLS handshake because of the client certificate.
+// The generic handshake failure counts only if the server asked for a client certificate (requested),
+// which it does after agreeing on the version and the cipher suite.
+// The other alerts do not prove that mutual TLS is enforced.
+func isClientCertificateRejected(err error, requested bool) bool {
+	if status.Code(err) != codes.Unavailable {
+		return false
+	}
+	msg := status.Convert(err).Message()
+	if requested && strings.Contains(msg, handshakeFailureAlert) {
+		return true
+	}
+	return slices.ContainsFunc(clientCertificateAlerts, func(alert string) bool {
+		return strings.Contains(msg, alert)
+	})
+}
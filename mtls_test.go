@@ -0,0 +1,118 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunClientExpectMTLSRequired(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	startServer := func(clientAuth tls.ClientAuthType, maxVersion uint16, cipherSuites ...uint16) string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   clientAuth,
+			MaxVersion:   maxVersion,
+			CipherSuites: cipherSuites,
+		})))
+		healthServer := health.NewServer()
+		healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+		grpc_health_v1.RegisterHealthServer(s, healthServer)
+		go func() {
+			if err := s.Serve(lis); err != nil {
+				t.Logf("Server stopped: %v", err)
+			}
+		}()
+		t.Cleanup(s.Stop)
+		return lis.Addr().String()
+	}
+
+	// Get an address with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		address string
+		opt     CLIClient
+		verify  bool
+		wantErr bool
+	}{
+		{name: "mTLS required", address: startServer(tls.RequireAnyClientCert, 0)},
+		{name: "mTLS required with TLS 1.2", address: startServer(tls.RequireAnyClientCert, tls.VersionTLS12)},
+		{name: "client certificate is not presented", address: startServer(tls.RequireAnyClientCert, 0), opt: CLIClient{Cert: certFile, Key: keyFile}},
+		{name: "client certificate optional", address: startServer(tls.RequestClientCert, 0), wantErr: true},
+		{name: "no client authentication", address: startServer(tls.NoClientCert, 0), wantErr: true},
+		{name: "connection refused", address: closedAddress, wantErr: true},
+		// The server aborts the handshake with an alert unrelated to the client certificate
+		{name: "no common cipher suite", address: startServer(tls.RequireAnyClientCert, tls.VersionTLS12, tls.TLS_RSA_WITH_RC4_128_SHA), wantErr: true},
+		// The client rejects the self-signed server certificate before the server can reject the client
+		{name: "server certificate not verified", address: startServer(tls.RequireAnyClientCert, 0), verify: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			opt := tt.opt
+			opt.Address = tt.address
+			opt.Insecure = !tt.verify
+			opt.ExpectMTLSRequired = true
+			err := runClient(ctx, opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsClientCertificateRejected(t *testing.T) {
+	alert := func(desc string) error {
+		return status.Error(codes.Unavailable, `connection error: desc = "transport: authentication handshake failed: remote error: tls: `+desc+`"`)
+	}
+	tests := []struct {
+		name      string
+		err       error
+		requested bool
+		want      bool
+	}{
+		{name: "certificate required", err: alert("certificate required"), requested: true, want: true},
+		{name: "bad certificate", err: alert("bad certificate"), requested: true, want: true},
+		{name: "handshake failure after the certificate request", err: alert("handshake failure"), requested: true, want: true},
+		{name: "handshake failure before the certificate request", err: alert("handshake failure")},
+		{name: "protocol version", err: alert("protocol version not supported"), requested: true},
+		{name: "internal error", err: alert("internal error"), requested: true},
+		{name: "not unavailable", err: status.Error(codes.Unknown, "remote error: tls: certificate required")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isClientCertificateRejected(tt.err, tt.requested); got != tt.want {
+				t.Errorf("isClientCertificateRejected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package grpchealth
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// checkResult is the JSON representation of a health check result for --output json
+type checkResult struct {
+	Address          string             `json:"address"`
+	Backend          string             `json:"backend,omitempty"`
+	Service          string             `json:"service"`
+	DeployID         string             `json:"deploy_id,omitempty"`
+	Seq              int64              `json:"seq,omitempty"`
+	EventTime        string             `json:"event_time,omitempty"`
+	Status           string             `json:"status,omitempty"`
+	DurationMs       float64            `json:"duration_ms,omitempty"`
+	LatencyExceeded  bool               `json:"latency_exceeded,omitempty"`
+	RequestSent      string             `json:"request_sent,omitempty"`
+	ResponseReceived string             `json:"response_received,omitempty"`
+	Peer             string             `json:"peer,omitempty"`
+	Certificate      *certificateResult `json:"certificate,omitempty"`
+	Error            string             `json:"error,omitempty"`
+	Code             string             `json:"code,omitempty"`
+	Reason           string             `json:"reason"`
+	RawStatus        json.RawMessage    `json:"raw_status,omitempty"`
+
+	timestamps  bool
+	warnLatency time.Duration
+	rawStatus   bool
+}
+
+type certificateResult struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+func newCheckResult(opt CLIClient) *checkResult {
+	return &checkResult{Address: opt.Address, Backend: opt.backend, Service: opt.Service, DeployID: opt.DeployID, timestamps: opt.Timestamps, warnLatency: opt.WarnLatency, rawStatus: opt.RawStatus}
+}
+
+// observe fills the result from the lifecycle events
+func (r *checkResult) observe(ev Event) {
+	if ev.Type != EventResponseReceived {
+		return
+	}
+	r.Status = ev.Status.String()
+	r.DurationMs = float64(ev.Duration) / float64(time.Millisecond)
+	r.LatencyExceeded = r.warnLatency > 0 && ev.Duration > r.warnLatency
+	if r.timestamps {
+		r.RequestSent = ev.Time.Add(-ev.Duration).Format(time.RFC3339Nano)
+		r.ResponseReceived = ev.Time.Format(time.RFC3339Nano)
+	}
+	if ev.Peer != nil {
+		r.Peer = ev.Peer.String()
+	}
+	if cert := ev.PeerCertificate; cert != nil {
+		r.Certificate = &certificateResult{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			NotAfter: cert.NotAfter,
+		}
+	}
+}
+
+// finish sets the error of the health check to the result
+func (r *checkResult) finish(err error) {
+	if err != nil {
+		r.Error = err.Error()
+		if st, ok := grpcStatus(err); ok {
+			r.Code = st.Code().String()
+		}
+		if r.rawStatus {
+			// errors without a gRPC status have no raw status
+			r.RawStatus, _ = rawStatus(err)
+		}
+	}
+	r.Reason = failureReason(err)
+}
+
+// write writes the result as a JSON line
+func (r *checkResult) write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// collectsResults reports whether any of the outputs needs the result of each check
+func collectsResults(opt CLIClient) bool {
+	return opt.Output == "json" || opt.Output == "junit" || opt.Summary || opt.JSONFile != ""
+}
+
+// writesStdout reports whether the results are written to stdout instead of the human-readable log
+func writesStdout(opt CLIClient) bool {
+	return opt.StatusCodeOnly || opt.Output == "json" || opt.Output == "junit" || opt.Summary && opt.SummaryFile == ""
+}
+
+// withoutResults returns the options for a nested check whose result is written by the caller
+func (opt CLIClient) withoutResults() CLIClient {
+	if opt.Output == "json" || opt.Output == "junit" {
+		// the raw status is in the result written by the caller
+		opt.RawStatus = false
+	}
+	opt.Output = "text"
+	opt.Summary, opt.SummaryFile, opt.results = false, "", nil
+	opt.JSONFile, opt.jsonFile = "", nil
+	return opt
+}
+
+// outputMu serializes the results of the checks run concurrently
+var outputMu sync.Mutex
+
+// writeResult outputs the result of a check to each of the outputs
+func writeResult(opt CLIClient, r *checkResult, err error) {
+	r.finish(err)
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if opt.Output == "json" {
+		if werr := r.write(opt.stdout()); werr != nil {
+			slog.Warn("Failed to write the result", "error", werr)
+		}
+	}
+	if opt.jsonFile != nil {
+		if werr := r.write(opt.jsonFile); werr != nil {
+			slog.Warn("Failed to write the result", "file", opt.JSONFile, "error", werr)
+		}
+	}
+	if opt.results != nil {
+		opt.results.add(r)
+	}
+}
+
+// resultSet collects the results of all the checks in a run for the JUnit report and the summary
+type resultSet struct {
+	mu      sync.Mutex
+	results []*checkResult
+}
+
+func (s *resultSet) add(r *checkResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, r)
+}
+
+func (s *resultSet) list() []*checkResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.results)
+}
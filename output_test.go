@@ -0,0 +1,715 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRunClientOutputJSON(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Get an address with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		address    string
+		service    string
+		wantStatus string
+		wantReason string
+		wantCode   string
+		wantError  bool
+		wantCert   bool
+	}{
+		{
+			name:       "serving",
+			address:    lis.Addr().String(),
+			wantStatus: "SERVING",
+			wantReason: "ok",
+			wantCert:   true,
+		},
+		{
+			name:       "not serving",
+			address:    lis.Addr().String(),
+			service:    "down",
+			wantStatus: "NOT_SERVING",
+			wantReason: "not_serving",
+			wantError:  true,
+			wantCert:   true,
+		},
+		{
+			name:       "unknown service",
+			address:    lis.Addr().String(),
+			service:    "unknown",
+			wantReason: "not_found",
+			wantCode:   "NotFound",
+			wantError:  true,
+		},
+		{
+			name:       "connection failure",
+			address:    closedAddress,
+			wantReason: "connect_failed",
+			wantCode:   "Unavailable",
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("Failed to create pipe: %v", err)
+			}
+			originalStdout := os.Stdout
+			os.Stdout = w
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			runClient(ctx, CLIClient{
+				Address:  tt.address,
+				Service:  tt.service,
+				TLS:      true,
+				Insecure: true,
+				Output:   "json",
+			})
+
+			os.Stdout = originalStdout
+			w.Close()
+			out, _ := io.ReadAll(r)
+
+			var got checkResult
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatalf("Output is not a JSON object: %v: %q", err, out)
+			}
+			if got.Address != tt.address || got.Service != tt.service {
+				t.Errorf("address, service = %q, %q", got.Address, got.Service)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			if got.Reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", got.Reason, tt.wantReason)
+			}
+			if (got.Error != "") != tt.wantError {
+				t.Errorf("error = %q, wantError %v", got.Error, tt.wantError)
+			}
+			if got.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", got.Code, tt.wantCode)
+			}
+			if tt.wantStatus != "" && (got.DurationMs <= 0 || got.Peer != tt.address) {
+				t.Errorf("duration_ms, peer = %v, %q", got.DurationMs, got.Peer)
+			}
+			if (got.Certificate != nil) != tt.wantCert {
+				t.Errorf("certificate = %+v, wantCert %v", got.Certificate, tt.wantCert)
+			}
+		})
+	}
+}
+
+func TestRunClientTimestamps(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Capture stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	originalStdout := os.Stdout
+	os.Stdout = w
+
+	before := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = runClient(ctx, CLIClient{
+		Address:    lis.Addr().String(),
+		Output:     "json",
+		Timestamps: true,
+	})
+	after := time.Now()
+
+	os.Stdout = originalStdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var got checkResult
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("Output is not a JSON object: %v: %q", err, out)
+	}
+	sent, err := time.Parse(time.RFC3339Nano, got.RequestSent)
+	if err != nil {
+		t.Fatalf("request_sent %q is not RFC3339Nano: %v", got.RequestSent, err)
+	}
+	received, err := time.Parse(time.RFC3339Nano, got.ResponseReceived)
+	if err != nil {
+		t.Fatalf("response_received %q is not RFC3339Nano: %v", got.ResponseReceived, err)
+	}
+	if sent.Before(before) || received.After(after) || received.Before(sent) {
+		t.Errorf("timestamps out of order: before=%s sent=%s received=%s after=%s", before, sent, received, after)
+	}
+	if d := float64(received.Sub(sent)) / float64(time.Millisecond); d-got.DurationMs > 1 || got.DurationMs-d > 1 {
+		t.Errorf("response_received - request_sent = %vms, want duration_ms %v", d, got.DurationMs)
+	}
+}
+
+func TestRunClientOutputJUnit(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// Capture stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	originalStdout := os.Stdout
+	os.Stdout = w
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = runClient(ctx, CLIClient{
+		Address:  lis.Addr().String(),
+		Services: []string{"", "down"},
+		Output:   "junit",
+	})
+
+	os.Stdout = originalStdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if err == nil {
+		t.Error("runClient() expected error, got nil")
+	}
+
+	var suite junitTestSuite
+	if err := xml.Unmarshal(out, &suite); err != nil {
+		t.Fatalf("Output is not a JUnit report: %v: %s", err, out)
+	}
+	if suite.Tests != 2 || suite.Failures != 1 || len(suite.TestCases) != 2 {
+		t.Fatalf("tests, failures, testcases = %d, %d, %d", suite.Tests, suite.Failures, len(suite.TestCases))
+	}
+	ok, failed := suite.TestCases[0], suite.TestCases[1]
+	if ok.Name != "(default)" || ok.ClassName != lis.Addr().String() || ok.Failure != nil {
+		t.Errorf("testcase[0] = %+v", ok)
+	}
+	if failed.Name != "down" || failed.Failure == nil || failed.Failure.Type != "not_serving" {
+		t.Errorf("testcase[1] = %+v", failed)
+	}
+	if _, err := time.ParseDuration(ok.Time + "s"); err != nil {
+		t.Errorf("testcase time %q is not in seconds: %v", ok.Time, err)
+	}
+}
+
+func TestRunClientSummary(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = runClient(ctx, CLIClient{
+		Address:     lis.Addr().String(),
+		Services:    []string{"", "down", "unknown"},
+		Output:      "text",
+		SummaryFile: summaryFile,
+	})
+	if err == nil {
+		t.Error("runClient() expected error, got nil")
+	}
+
+	b, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatalf("Failed to read the summary: %v", err)
+	}
+	var got runSummary
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Summary is not a JSON object: %v: %s", err, b)
+	}
+	if got.Total != 3 {
+		t.Errorf("total = %d, want 3", got.Total)
+	}
+	if got.Statuses["SERVING"] != 1 || got.Statuses["NOT_SERVING"] != 1 || got.Statuses["ERROR"] != 1 {
+		t.Errorf("statuses = %v", got.Statuses)
+	}
+	if got.SuccessRate < 0.33 || got.SuccessRate > 0.34 {
+		t.Errorf("success_rate = %v, want 1/3", got.SuccessRate)
+	}
+	if got.Latency == nil || got.Latency.Min <= 0 || got.Latency.Min > got.Latency.Avg || got.Latency.Avg > got.Latency.Max {
+		t.Errorf("latency_ms = %+v", got.Latency)
+	}
+	if len(got.Unhealthy) != 2 {
+		t.Fatalf("unhealthy = %+v, want 2 targets", got.Unhealthy)
+	}
+	for _, u := range got.Unhealthy {
+		if u.Address != lis.Addr().String() || (u.Service != "down" && u.Service != "unknown") {
+			t.Errorf("unhealthy target = %+v", u)
+		}
+	}
+}
+
+func TestSummarizeLatestResult(t *testing.T) {
+	// A target that recovered is not listed as unhealthy
+	results := []*checkResult{
+		{Address: "a:1", Status: "NOT_SERVING", DurationMs: 2, Reason: "not_serving", Error: "not serving"},
+		{Address: "b:1", Reason: "connect_failed", Error: "connection refused"},
+		{Address: "a:1", Status: "SERVING", DurationMs: 4, Reason: "ok"},
+	}
+	got := summarize(results)
+	if got.Total != 3 || got.Statuses["ERROR"] != 1 {
+		t.Errorf("total, statuses = %d, %v", got.Total, got.Statuses)
+	}
+	if got.Latency == nil || got.Latency.Min != 2 || got.Latency.Avg != 3 || got.Latency.Max != 4 {
+		t.Errorf("latency_ms = %+v", got.Latency)
+	}
+	if len(got.Unhealthy) != 1 || got.Unhealthy[0].Address != "b:1" {
+		t.Errorf("unhealthy = %+v", got.Unhealthy)
+	}
+}
+
+func TestRunClientStdout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	var buf bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = RunClient(ctx, CLIClient{
+		Address:  lis.Addr().String(),
+		Services: []string{"", "down"},
+		Output:   "json",
+		Summary:  true,
+		Stdout:   &buf,
+	})
+	if err == nil {
+		t.Error("RunClient() expected error, got nil")
+	}
+
+	// Two results followed by the summary
+	dec := json.NewDecoder(&buf)
+	for _, want := range []string{"SERVING", "NOT_SERVING"} {
+		var got checkResult
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode the result: %v", err)
+		}
+		if got.Status != want {
+			t.Errorf("status = %q, want %q", got.Status, want)
+		}
+	}
+	var summary runSummary
+	if err := dec.Decode(&summary); err != nil {
+		t.Fatalf("Failed to decode the summary: %v", err)
+	}
+	if summary.Total != 2 || len(summary.Unhealthy) != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestRunClientJSONFile(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name       string
+		output     string
+		wantStdout int
+	}{
+		// the human-readable log goes to slog, not to stdout
+		{name: "with text output", output: "text", wantStdout: 0},
+		{name: "with json output", output: "json", wantStdout: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonFile := filepath.Join(t.TempDir(), "results.jsonl")
+			var stdout bytes.Buffer
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := runClient(ctx, CLIClient{
+				Address:  lis.Addr().String(),
+				Services: []string{"", "down"},
+				Output:   tt.output,
+				JSONFile: jsonFile,
+				Stdout:   &stdout,
+			})
+			if err == nil {
+				t.Error("runClient() expected error, got nil")
+			}
+			if got := strings.Count(stdout.String(), "\n"); got != tt.wantStdout {
+				t.Errorf("stdout has %d lines, want %d: %s", got, tt.wantStdout, stdout.String())
+			}
+
+			b, err := os.ReadFile(jsonFile)
+			if err != nil {
+				t.Fatalf("Failed to read the JSON file: %v", err)
+			}
+			dec := json.NewDecoder(bytes.NewReader(b))
+			for _, want := range []string{"SERVING", "NOT_SERVING"} {
+				var got checkResult
+				if err := dec.Decode(&got); err != nil {
+					t.Fatalf("Failed to decode the result: %v: %s", err, b)
+				}
+				if got.Status != want {
+					t.Errorf("status = %q, want %q", got.Status, want)
+				}
+			}
+			if dec.More() {
+				t.Errorf("Unexpected extra results: %s", b)
+			}
+		})
+	}
+}
+
+func TestRunClientJSONFileAutoTLS(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// The failed TLS attempt is not written, only the plaintext fallback
+	jsonFile := filepath.Join(t.TempDir(), "results.jsonl")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = runClient(ctx, CLIClient{
+		Address:  lis.Addr().String(),
+		AutoTLS:  true,
+		Insecure: true,
+		Output:   "text",
+		JSONFile: jsonFile,
+	})
+	if err != nil {
+		t.Fatalf("runClient() error = %v", err)
+	}
+	b, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatalf("Failed to read the JSON file: %v", err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 1 {
+		t.Errorf("JSON file has %d lines, want 1: %s", n, b)
+	}
+}
+
+func TestRunClientDeployID(t *testing.T) {
+	address := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	t.Run("json and summary", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := runClient(ctx, CLIClient{
+			Address:  address,
+			Output:   "json",
+			Summary:  true,
+			DeployID: "v1.2.3",
+			Stdout:   &buf,
+		})
+		if err != nil {
+			t.Fatalf("runClient() error = %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected a result and a summary, got: %s", buf.String())
+		}
+		var result checkResult
+		if err := json.Unmarshal([]byte(lines[0]), &result); err != nil {
+			t.Fatalf("Result is not a JSON object: %v: %s", err, lines[0])
+		}
+		if result.DeployID != "v1.2.3" {
+			t.Errorf("result deploy_id = %q, want v1.2.3", result.DeployID)
+		}
+		var summary runSummary
+		if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+			t.Fatalf("Summary is not a JSON object: %v: %s", err, lines[1])
+		}
+		if summary.DeployID != "v1.2.3" {
+			t.Errorf("summary deploy_id = %q, want v1.2.3", summary.DeployID)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := runClient(ctx, CLIClient{
+			Address:  address,
+			Output:   "junit",
+			DeployID: "v1.2.3",
+			Stdout:   &buf,
+		})
+		if err != nil {
+			t.Fatalf("runClient() error = %v", err)
+		}
+		var suite junitTestSuite
+		if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+			t.Fatalf("Output is not a JUnit report: %v: %s", err, buf.String())
+		}
+		if suite.Properties == nil || len(suite.Properties.Properties) != 1 ||
+			suite.Properties.Properties[0] != (junitProperty{Name: "deploy_id", Value: "v1.2.3"}) {
+			t.Errorf("properties = %+v", suite.Properties)
+		}
+	})
+
+	t.Run("omitted without deploy ID", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := runClient(ctx, CLIClient{Address: address, Output: "json", Stdout: &buf}); err != nil {
+			t.Fatalf("runClient() error = %v", err)
+		}
+		if strings.Contains(buf.String(), "deploy_id") {
+			t.Errorf("Expected no deploy_id, got: %s", buf.String())
+		}
+	})
+}
+
+func TestRunClientOutputLatencyExceeded(t *testing.T) {
+	address := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	tests := []struct {
+		name        string
+		warnLatency time.Duration
+		want        bool
+	}{
+		{name: "exceeded", warnLatency: time.Nanosecond, want: true},
+		{name: "within", warnLatency: time.Hour},
+		{name: "disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			var buf bytes.Buffer
+			err := runClient(ctx, CLIClient{
+				Address:     address,
+				Output:      "json",
+				Summary:     true,
+				WarnLatency: tt.warnLatency,
+				Stdout:      &buf,
+			})
+			if err != nil {
+				t.Fatalf("runClient() error = %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("Expected a result and a summary, got: %s", buf.String())
+			}
+			var result checkResult
+			if err := json.Unmarshal([]byte(lines[0]), &result); err != nil {
+				t.Fatalf("Result is not a JSON object: %v: %s", err, lines[0])
+			}
+			if result.LatencyExceeded != tt.want {
+				t.Errorf("latency_exceeded = %v, want %v", result.LatencyExceeded, tt.want)
+			}
+			var summary runSummary
+			if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+				t.Fatalf("Summary is not a JSON object: %v: %s", err, lines[1])
+			}
+			if want := map[bool]int{true: 1}[tt.want]; summary.LatencyExceeded != want {
+				t.Errorf("summary latency_exceeded = %d, want %d", summary.LatencyExceeded, want)
+			}
+		})
+	}
+
+	t.Run("junit", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		var buf bytes.Buffer
+		err := runClient(ctx, CLIClient{
+			Address:     address,
+			Output:      "junit",
+			WarnLatency: time.Nanosecond,
+			Stdout:      &buf,
+		})
+		if err != nil {
+			t.Fatalf("runClient() error = %v", err)
+		}
+		var suite junitTestSuite
+		if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+			t.Fatalf("Output is not a JUnit report: %v: %s", err, buf.String())
+		}
+		if len(suite.TestCases) != 1 || suite.TestCases[0].Properties == nil ||
+			suite.TestCases[0].Properties.Properties[0] != (junitProperty{Name: "latency_exceeded", Value: "true"}) {
+			t.Errorf("testcases = %+v", suite.TestCases)
+		}
+	})
+}
+
+func TestRunClientRawStatus(t *testing.T) {
+	address := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{name: "text", output: "text"},
+		{name: "json", output: "json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			var buf bytes.Buffer
+			err := runClient(ctx, CLIClient{
+				Address:   address,
+				Service:   "unknown",
+				Output:    tt.output,
+				RawStatus: true,
+				Stdout:    &buf,
+			})
+			if err == nil {
+				t.Fatal("runClient() expected error, got nil")
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("Expected a single JSON line, got: %s", buf.String())
+			}
+			raw := []byte(lines[0])
+			if tt.output == "json" {
+				var result checkResult
+				if err := json.Unmarshal(raw, &result); err != nil {
+					t.Fatalf("Result is not a JSON object: %v: %s", err, raw)
+				}
+				raw = result.RawStatus
+			}
+			var st struct {
+				Code int `json:"code"`
+			}
+			if err := json.Unmarshal(raw, &st); err != nil {
+				t.Fatalf("Raw status is not JSON: %v: %s", err, raw)
+			}
+			if st.Code != int(codes.NotFound) {
+				t.Errorf("code = %d, want %d", st.Code, codes.NotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package grpchealth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// prefixedHealthClient calls the health service mounted under a path prefix by an API gateway.
+// The method path /grpc.health.v1.Health/Check is sent as /<prefix>/grpc.health.v1.Health/Check.
+type prefixedHealthClient struct {
+	cc     grpc.ClientConnInterface
+	prefix string
+}
+
+// newPrefixedHealthClient returns a health client that rewrites the method paths with the prefix
+func newPrefixedHealthClient(cc grpc.ClientConnInterface, prefix string) (grpc_health_v1.HealthClient, error) {
+	p := "/" + strings.Trim(prefix, "/")
+	if p == "/" {
+		return nil, fmt.Errorf("invalid path prefix %q", prefix)
+	}
+	return &prefixedHealthClient{cc: cc, prefix: p}, nil
+}
+
+func (c *prefixedHealthClient) method(fullMethod string) string {
+	return c.prefix + fullMethod
+}
+
+func (c *prefixedHealthClient) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
+	out := new(grpc_health_v1.HealthCheckResponse)
+	if err := c.cc.Invoke(ctx, c.method(grpc_health_v1.Health_Check_FullMethodName), in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prefixedHealthClient) List(ctx context.Context, in *grpc_health_v1.HealthListRequest, opts ...grpc.CallOption) (*grpc_health_v1.HealthListResponse, error) {
+	out := new(grpc_health_v1.HealthListResponse)
+	if err := c.cc.Invoke(ctx, c.method(grpc_health_v1.Health_List_FullMethodName), in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prefixedHealthClient) Watch(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[grpc_health_v1.HealthCheckResponse], error) {
+	stream, err := c.cc.NewStream(ctx, &grpc_health_v1.Health_ServiceDesc.Streams[0], c.method(grpc_health_v1.Health_Watch_FullMethodName), opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
@@ -0,0 +1,69 @@
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunClientPathPrefix(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	// Emulate a gateway that serves the health service only under /api
+	s := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		switch method {
+		case "/api" + grpc_health_v1.Health_Check_FullMethodName, "/api" + grpc_health_v1.Health_Watch_FullMethodName:
+		default:
+			return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+		}
+		var req grpc_health_v1.HealthCheckRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return stream.SendMsg(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+	}))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name    string
+		opt     CLIClient
+		wantErr bool
+	}{
+		{name: "check with prefix", opt: CLIClient{PathPrefix: "/api"}},
+		{name: "prefix without slashes", opt: CLIClient{PathPrefix: "api"}},
+		{name: "watch with prefix", opt: CLIClient{PathPrefix: "/api/", Watch: true}},
+		{name: "without prefix", opt: CLIClient{}, wantErr: true},
+		{name: "wrong prefix", opt: CLIClient{PathPrefix: "/v1"}, wantErr: true},
+		{name: "invalid prefix", opt: CLIClient{PathPrefix: "/"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			opt := tt.opt
+			opt.Address = lis.Addr().String()
+			err := runClient(ctx, opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
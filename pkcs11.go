@@ -0,0 +1,175 @@
+package grpchealth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// pkcs11URI is a PKCS#11 URI (RFC 7512) of a private key, e.g.,
+// pkcs11:token=hsm;object=server-key?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-source=/run/secrets/pin
+type pkcs11URI struct {
+	modulePath string
+	token      string
+	object     string
+	id         []byte
+	pin        string
+}
+
+// parsePKCS11URI parses the PKCS#11 URI of --key-pkcs11
+func parsePKCS11URI(s string) (pkcs11URI, error) {
+	var uri pkcs11URI
+	rest, ok := strings.CutPrefix(s, "pkcs11:")
+	if !ok {
+		return uri, fmt.Errorf("invalid PKCS#11 URI %q: must start with pkcs11:", s)
+	}
+	path, query, _ := strings.Cut(rest, "?")
+	var pinSource string
+	for _, attrs := range []struct {
+		s   string
+		sep string
+	}{{path, ";"}, {query, "&"}} {
+		if attrs.s == "" {
+			continue
+		}
+		for _, attr := range strings.Split(attrs.s, attrs.sep) {
+			name, value, ok := strings.Cut(attr, "=")
+			if !ok {
+				return uri, fmt.Errorf("invalid PKCS#11 URI %q: attribute %q has no value", s, attr)
+			}
+			v, err := url.PathUnescape(value)
+			if err != nil {
+				return uri, fmt.Errorf("invalid PKCS#11 URI %q: %w", s, err)
+			}
+			switch name {
+			case "token":
+				uri.token = v
+			case "object":
+				uri.object = v
+			case "id":
+				uri.id = []byte(v)
+			case "type":
+				if v != "private" {
+					return uri, fmt.Errorf("invalid PKCS#11 URI %q: type must be private", s)
+				}
+			case "module-path":
+				uri.modulePath = v
+			case "pin-value":
+				uri.pin = v
+			case "pin-source":
+				pinSource = strings.TrimPrefix(v, "file:")
+			default:
+				if !strings.HasPrefix(name, "x-") {
+					return uri, fmt.Errorf("invalid PKCS#11 URI %q: unsupported attribute %q", s, name)
+				}
+			}
+		}
+	}
+	if uri.modulePath == "" {
+		return uri, fmt.Errorf("invalid PKCS#11 URI %q: module-path is required", s)
+	}
+	if uri.object == "" && uri.id == nil {
+		return uri, fmt.Errorf("invalid PKCS#11 URI %q: object or id is required to find the key", s)
+	}
+	if pinSource != "" {
+		if uri.pin != "" {
+			return uri, fmt.Errorf("invalid PKCS#11 URI %q: pin-value and pin-source are exclusive", s)
+		}
+		b, err := os.ReadFile(pinSource)
+		if err != nil {
+			return uri, fmt.Errorf("failed to read the PIN: %w", err)
+		}
+		uri.pin = strings.TrimRight(string(b), "\r\n")
+	}
+	return uri, nil
+}
+
+// openPKCS11Signer opens the private key in the token, whose public key is public.
+// It is replaceable for testing without a token.
+var openPKCS11Signer = openPKCS11Token
+
+// pkcs11KeyPairLoader returns the loader of the certificate in certFile with the private key in the token.
+// The key is opened once at the first load, and a reloaded certificate must be of the same key.
+func pkcs11KeyPairLoader(certFile, rawURI string) (func() (tls.Certificate, error), error) {
+	uri, err := parsePKCS11URI(rawURI)
+	if err != nil {
+		return nil, err
+	}
+	var signer crypto.Signer
+	return func() (tls.Certificate, error) {
+		chain, leaf, err := loadCertificateChain(certFile)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		if signer == nil {
+			s, err := openPKCS11Signer(uri, leaf.PublicKey)
+			if err != nil {
+				return tls.Certificate{}, fmt.Errorf("failed to open the PKCS#11 key: %w", err)
+			}
+			if err := verifyKeyPair(s, leaf); err != nil {
+				return tls.Certificate{}, err
+			}
+			signer = s
+		} else if pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(leaf.PublicKey) {
+			return tls.Certificate{}, fmt.Errorf("the certificate in %s is not of the PKCS#11 key", certFile)
+		}
+		return tls.Certificate{Certificate: chain, PrivateKey: signer, Leaf: leaf}, nil
+	}, nil
+}
+
+// loadCertificateChain loads the PEM encoded certificate chain in the file, with the leaf certificate first
+func loadCertificateChain(file string) ([][]byte, *x509.Certificate, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	var chain [][]byte
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			chain = append(chain, block.Bytes)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate in %s: %w", file, err)
+	}
+	return chain, leaf, nil
+}
+
+// verifyKeyPair signs a test digest with the signer and verifies it with the certificate,
+// so that a wrong key or PIN fails at startup instead of in every handshake
+func verifyKeyPair(signer crypto.Signer, leaf *x509.Certificate) error {
+	digest := sha256.Sum256([]byte("grpchealth key pair check"))
+	var algorithm x509.SignatureAlgorithm
+	switch leaf.PublicKeyAlgorithm {
+	case x509.RSA:
+		algorithm = x509.SHA256WithRSA
+	case x509.ECDSA:
+		algorithm = x509.ECDSAWithSHA256
+	default:
+		return fmt.Errorf("unsupported key algorithm %s of the PKCS#11 key", leaf.PublicKeyAlgorithm)
+	}
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign with the PKCS#11 key: %w", err)
+	}
+	if err := leaf.CheckSignature(algorithm, []byte("grpchealth key pair check"), sig); err != nil {
+		return fmt.Errorf("the PKCS#11 key does not match the certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePKCS11URI(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	if err := os.WriteFile(pinFile, []byte("1234\n"), 0600); err != nil {
+		t.Fatalf("Failed to write the PIN file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    pkcs11URI
+		wantErr bool
+	}{
+		{
+			name: "object and pin-value",
+			uri:  "pkcs11:token=hsm;object=server%20key;type=private?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234",
+			want: pkcs11URI{modulePath: "/usr/lib/softhsm/libsofthsm2.so", token: "hsm", object: "server key", pin: "1234"},
+		},
+		{
+			name: "id and pin-source",
+			uri:  "pkcs11:id=%01%02;x-vendor=foo?module-path=/lib/p11.so&pin-source=file:" + pinFile,
+			want: pkcs11URI{modulePath: "/lib/p11.so", id: []byte{1, 2}, pin: "1234"},
+		},
+		{name: "not a PKCS#11 URI", uri: "/path/to/key.pem", wantErr: true},
+		{name: "without module-path", uri: "pkcs11:object=key", wantErr: true},
+		{name: "without object or id", uri: "pkcs11:token=hsm?module-path=/lib/p11.so", wantErr: true},
+		{name: "public key", uri: "pkcs11:object=key;type=public?module-path=/lib/p11.so", wantErr: true},
+		{name: "unsupported attribute", uri: "pkcs11:object=key;serial=1?module-path=/lib/p11.so", wantErr: true},
+		{name: "invalid escape", uri: "pkcs11:object=%zz?module-path=/lib/p11.so", wantErr: true},
+		{name: "both pin-value and pin-source", uri: "pkcs11:object=key?module-path=/lib/p11.so&pin-value=1&pin-source=" + pinFile, wantErr: true},
+		{name: "missing pin-source", uri: "pkcs11:object=key?module-path=/lib/p11.so&pin-source=/nonexistent/pin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePKCS11URI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePKCS11URI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePKCS11URI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// useSoftwarePKCS11Key replaces the token with the key in keyFile until the end of the test
+func useSoftwarePKCS11Key(t *testing.T, certFile, keyFile string) *[]pkcs11URI {
+	t.Helper()
+	var opened []pkcs11URI
+	original := openPKCS11Signer
+	openPKCS11Signer = func(uri pkcs11URI, _ crypto.PublicKey) (crypto.Signer, error) {
+		opened = append(opened, uri)
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PrivateKey.(crypto.Signer), nil
+	}
+	t.Cleanup(func() { openPKCS11Signer = original })
+	return &opened
+}
+
+func TestRunServerKeyPKCS11(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	opened := useSoftwarePKCS11Key(t, certFile, keyFile)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, CLIServer{
+			Address:   address,
+			CertFile:  certFile,
+			KeyPKCS11: "pkcs11:token=hsm;object=server?module-path=/lib/p11.so&pin-value=1234",
+		})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	if err := runClient(ctx, CLIClient{Address: address, TLS: true, CAFile: certFile}); err != nil {
+		t.Errorf("runClient() error = %v", err)
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+	want := []pkcs11URI{{modulePath: "/lib/p11.so", token: "hsm", object: "server", pin: "1234"}}
+	if !reflect.DeepEqual(*opened, want) {
+		t.Errorf("opened %+v, want %+v", *opened, want)
+	}
+}
+
+func TestRunServerKeyPKCS11Errors(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	otherCert, otherKey, otherCleanup := createTempCertFiles(t)
+	defer otherCleanup()
+	const uri = "pkcs11:object=server?module-path=/lib/p11.so"
+
+	tests := []struct {
+		name   string
+		opt    CLIServer
+		signer func(*testing.T)
+	}{
+		{name: "with key file", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, KeyPKCS11: uri}},
+		{name: "without certificate", opt: CLIServer{KeyPKCS11: uri}},
+		{name: "invalid URI", opt: CLIServer{CertFile: certFile, KeyPKCS11: "pkcs11:object=server"}},
+		{name: "module not found", opt: CLIServer{CertFile: certFile, KeyPKCS11: "pkcs11:object=server?module-path=/nonexistent/p11.so"}},
+		{
+			name:   "key of another certificate",
+			opt:    CLIServer{CertFile: certFile, KeyPKCS11: uri},
+			signer: func(t *testing.T) { useSoftwarePKCS11Key(t, otherCert, otherKey) },
+		},
+		{
+			name: "token error",
+			opt:  CLIServer{CertFile: certFile, KeyPKCS11: uri},
+			signer: func(t *testing.T) {
+				original := openPKCS11Signer
+				openPKCS11Signer = func(pkcs11URI, crypto.PublicKey) (crypto.Signer, error) {
+					return nil, errors.New("CKR_PIN_INCORRECT")
+				}
+				t.Cleanup(func() { openPKCS11Signer = original })
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.signer != nil {
+				tt.signer(t)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			opt := tt.opt
+			opt.Address = "127.0.0.1:0"
+			if err := runServer(ctx, opt); err == nil {
+				t.Error("runServer() expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+//go:build pkcs11 && cgo
+
+package grpchealth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// openPKCS11Token logs in to the token and finds the private key of the URI
+func openPKCS11Token(uri pkcs11URI, public crypto.PublicKey) (crypto.Signer, error) {
+	ctx := pkcs11.New(uri.modulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load the PKCS#11 module %s", uri.modulePath)
+	}
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		return nil, fmt.Errorf("failed to initialize the PKCS#11 module: %w", err)
+	}
+	slot, err := findPKCS11Slot(ctx, uri.token)
+	if err != nil {
+		return nil, err
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a PKCS#11 session: %w", err)
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, uri.pin); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return nil, fmt.Errorf("failed to log in to the PKCS#11 token: %w", err)
+	}
+	key, err := findPKCS11Key(ctx, session, uri)
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs11Signer{
+		public: public,
+		sign: func(mechanism *pkcs11.Mechanism, data []byte) ([]byte, error) {
+			if err := ctx.SignInit(session, []*pkcs11.Mechanism{mechanism}, key); err != nil {
+				return nil, err
+			}
+			return ctx.Sign(session, data)
+		},
+	}, nil
+}
+
+// findPKCS11Slot returns the slot of the token with the label, or the only token if the label is empty
+func findPKCS11Slot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the PKCS#11 slots: %w", err)
+	}
+	if label == "" {
+		if len(slots) != 1 {
+			return 0, fmt.Errorf("%d PKCS#11 tokens found, specify token in the URI", len(slots))
+		}
+		return slots[0], nil
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get the PKCS#11 token info: %w", err)
+		}
+		if info.Label == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("PKCS#11 token %q not found", label)
+}
+
+// findPKCS11Key returns the only private key with the object label and the id of the URI
+func findPKCS11Key(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, uri pkcs11URI) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY)}
+	if uri.object != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, uri.object))
+	}
+	if uri.id != nil {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, uri.id))
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("failed to find the PKCS#11 key: %w", err)
+	}
+	objects, _, err := ctx.FindObjects(session, 2)
+	if finalErr := ctx.FindObjectsFinal(session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the PKCS#11 key: %w", err)
+	}
+	switch len(objects) {
+	case 0:
+		return 0, errors.New("PKCS#11 key not found")
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, errors.New("multiple PKCS#11 keys found, specify object or id in the URI")
+	}
+}
+
+// pkcs11Signer signs TLS handshakes with a key in a PKCS#11 token
+type pkcs11Signer struct {
+	public crypto.PublicKey
+	// mu serializes the operations on the session, which is not safe for concurrent use
+	mu   sync.Mutex
+	sign func(mechanism *pkcs11.Mechanism, data []byte) ([]byte, error)
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// pkcs11HashMechanisms are the hash and MGF1 mechanisms of RSA-PSS
+var pkcs11HashMechanisms = map[crypto.Hash]struct{ hash, mgf uint }{
+	crypto.SHA1:   {pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1},
+	crypto.SHA224: {pkcs11.CKM_SHA224, pkcs11.CKG_MGF1_SHA224},
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+// pkcs1DigestInfoPrefixes are the DER prefixes of DigestInfo, which CKM_RSA_PKCS does not add
+var pkcs1DigestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:    {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224:  {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256:  {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384:  {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512:  {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+	crypto.MD5SHA1: {},
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash := opts.HashFunc()
+	switch pub := s.public.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			m, ok := pkcs11HashMechanisms[hash]
+			if !ok {
+				return nil, fmt.Errorf("unsupported hash %s for RSA-PSS", hash)
+			}
+			saltLength := pss.SaltLength
+			if saltLength == rsa.PSSSaltLengthAuto || saltLength == rsa.PSSSaltLengthEqualsHash {
+				saltLength = hash.Size()
+			}
+			params := pkcs11.NewPSSParams(m.hash, m.mgf, uint(saltLength))
+			return s.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params), digest)
+		}
+		prefix, ok := pkcs1DigestInfoPrefixes[hash]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash %s for RSA PKCS #1 v1.5", hash)
+		}
+		return s.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil), append(prefix[:len(prefix):len(prefix)], digest...))
+	case *ecdsa.PublicKey:
+		sig, err := s.sign(pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), digest)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsaASN1Signature(sig)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// ecdsaASN1Signature converts the r||s signature of CKM_ECDSA into the ASN.1 encoding of TLS
+func ecdsaASN1Signature(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, fmt.Errorf("invalid ECDSA signature length %d", len(sig))
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(sig[:len(sig)/2]),
+		new(big.Int).SetBytes(sig[len(sig)/2:]),
+	})
+}
@@ -0,0 +1,13 @@
+//go:build !pkcs11 || !cgo
+
+package grpchealth
+
+import (
+	"crypto"
+	"errors"
+)
+
+// openPKCS11Token is not available without the pkcs11 build tag, which needs cgo
+func openPKCS11Token(pkcs11URI, crypto.PublicKey) (crypto.Signer, error) {
+	return nil, errors.New("--key-pkcs11 is not supported by this binary, build it with -tags pkcs11 (requires cgo)")
+}
@@ -0,0 +1,170 @@
+//go:build pkcs11 && cgo
+
+package grpchealth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+)
+
+// softwarePKCS11Signer returns a pkcs11Signer performing the token mechanisms with the software key
+func softwarePKCS11Signer(t *testing.T, key crypto.Signer) *pkcs11Signer {
+	t.Helper()
+	return &pkcs11Signer{
+		public: key.Public(),
+		sign: func(mechanism *pkcs11.Mechanism, data []byte) ([]byte, error) {
+			switch k := key.(type) {
+			case *rsa.PrivateKey:
+				switch mechanism.Mechanism {
+				case pkcs11.CKM_RSA_PKCS:
+					// data is the DigestInfo, which is signed as is
+					return rsa.SignPKCS1v15(nil, k, 0, data)
+				case pkcs11.CKM_RSA_PKCS_PSS:
+					var hash crypto.Hash
+					for h, m := range pkcs11HashMechanisms {
+						if mechanism.Parameter[0] == byte(m.hash) {
+							hash = h
+						}
+					}
+					return rsa.SignPSS(rand.Reader, k, hash, data, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+				}
+			case *ecdsa.PrivateKey:
+				if mechanism.Mechanism == pkcs11.CKM_ECDSA {
+					r, s, err := ecdsa.Sign(rand.Reader, k, data)
+					if err != nil {
+						return nil, err
+					}
+					size := (k.Curve.Params().BitSize + 7) / 8
+					return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), nil
+				}
+			}
+			return nil, fmt.Errorf("unexpected mechanism %#x", mechanism.Mechanism)
+		},
+	}
+}
+
+func TestPKCS11Signer(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+	digest := sha256.Sum256([]byte("hello"))
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		opts   crypto.SignerOpts
+		verify func(sig []byte) error
+	}{
+		{
+			name: "RSA PKCS #1 v1.5",
+			key:  rsaKey,
+			opts: crypto.SHA256,
+			verify: func(sig []byte) error {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig)
+			},
+		},
+		{
+			name: "RSA-PSS",
+			key:  rsaKey,
+			opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256},
+			verify: func(sig []byte) error {
+				return rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, nil)
+			},
+		},
+		{
+			name: "ECDSA",
+			key:  ecKey,
+			opts: crypto.SHA256,
+			verify: func(sig []byte) error {
+				if !ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig) {
+					return fmt.Errorf("invalid signature")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := softwarePKCS11Signer(t, tt.key).Sign(rand.Reader, digest[:], tt.opts)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if err := tt.verify(sig); err != nil {
+				t.Errorf("verify error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPKCS11SignerHandshake(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+			t.Run(fmt.Sprintf("%T/%s", key, tls.VersionName(version)), func(t *testing.T) {
+				template := &x509.Certificate{
+					SerialNumber: big.NewInt(1),
+					Subject:      pkix.Name{CommonName: "localhost"},
+					NotBefore:    time.Now(),
+					NotAfter:     time.Now().Add(time.Hour),
+					DNSNames:     []string{"localhost"},
+				}
+				der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+				if err != nil {
+					t.Fatalf("Failed to create certificate: %v", err)
+				}
+				leaf, err := x509.ParseCertificate(der)
+				if err != nil {
+					t.Fatalf("Failed to parse certificate: %v", err)
+				}
+				signer := softwarePKCS11Signer(t, key)
+				if err := verifyKeyPair(signer, leaf); err != nil {
+					t.Fatalf("verifyKeyPair() error = %v", err)
+				}
+
+				pool := x509.NewCertPool()
+				pool.AddCert(leaf)
+				serverConn, clientConn := net.Pipe()
+				defer clientConn.Close()
+				go func() {
+					server := tls.Server(serverConn, &tls.Config{
+						Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: signer, Leaf: leaf}},
+						MaxVersion:   version,
+					})
+					server.Handshake()
+					server.Close()
+				}()
+				client := tls.Client(clientConn, &tls.Config{RootCAs: pool, ServerName: "localhost", MinVersion: version})
+				if err := client.Handshake(); err != nil {
+					t.Errorf("Handshake() error = %v", err)
+				}
+			})
+		}
+	}
+}
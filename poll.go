@@ -0,0 +1,56 @@
+package grpchealth
+
+import (
+	"context"
+	"log/slog"
+)
+
+// runPollClient checks the health every interval until the context is done.
+// A change of the status between polls is logged as a warning.
+func runPollClient(ctx context.Context, opt CLIClient) error {
+	o := opt
+	o.Interval = 0
+	var current string
+	o.OnEvent = func(ev Event) {
+		if ev.Type == EventResponseReceived {
+			current = ev.Status.String()
+		}
+		if opt.OnEvent != nil {
+			opt.OnEvent(ev)
+		}
+	}
+
+	ticker := clk.NewTicker(opt.Interval)
+	defer ticker.Stop()
+	var last string
+	for {
+		current = ""
+		err := runClient(ctx, o)
+		if current == "" && ctx.Err() != nil {
+			// interrupted before the response
+			return nil
+		}
+		if err != nil {
+			slog.Error("Health check failed", "address", opt.Address, "error", err)
+		}
+		if current == "" {
+			// no response from the server
+			current = "ERROR"
+		}
+		if last != "" && current != last {
+			slog.Warn("Health status changed",
+				"address", opt.Address,
+				"service", opt.Service,
+				"from", last,
+				"to", current,
+			)
+		}
+		last = current
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRunClientInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []string
+		service  string
+		wantLog  string
+	}{
+		{name: "default service", service: "", wantLog: `service=""`},
+		// A single --service is checked as the service, and logged with it
+		{name: "single service", services: []string{"foo"}, service: "foo", wantLog: "service=foo "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			defer lis.Close()
+
+			s := grpc.NewServer()
+			healthServer := health.NewServer()
+			healthServer.SetServingStatus(tt.service, grpc_health_v1.HealthCheckResponse_SERVING)
+			grpc_health_v1.RegisterHealthServer(s, healthServer)
+			go func() {
+				if err := s.Serve(lis); err != nil {
+					t.Logf("Server stopped: %v", err)
+				}
+			}()
+			defer s.Stop()
+
+			// Capture log output
+			var buf bytes.Buffer
+			originalLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(originalLogger)
+
+			statuses := make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 100)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			pollCtx, stop := context.WithCancel(ctx)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runClient(pollCtx, CLIClient{
+					Address:  lis.Addr().String(),
+					Services: tt.services,
+					Interval: 20 * time.Millisecond,
+					OnEvent: func(ev Event) {
+						if ev.Type == EventResponseReceived {
+							statuses <- ev.Status
+						}
+					},
+				})
+			}()
+
+			wait := func(want grpc_health_v1.HealthCheckResponse_ServingStatus) {
+				t.Helper()
+				for {
+					select {
+					case got := <-statuses:
+						if got == want {
+							return
+						}
+					case <-ctx.Done():
+						t.Fatalf("timed out waiting for %s", want)
+					}
+				}
+			}
+			wait(grpc_health_v1.HealthCheckResponse_SERVING)
+			wait(grpc_health_v1.HealthCheckResponse_SERVING)
+			healthServer.SetServingStatus(tt.service, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+			wait(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+			stop()
+			select {
+			case err := <-errCh:
+				if err != nil {
+					t.Errorf("runClient() error = %v, want nil", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("runClient() did not stop after the context was canceled")
+			}
+			logs := buf.String()
+			var warning string
+			for _, line := range strings.Split(logs, "\n") {
+				if strings.Contains(line, `level=WARN msg="Health status changed"`) {
+					warning = line
+					break
+				}
+			}
+			if !strings.Contains(warning, "from=SERVING to=NOT_SERVING") {
+				t.Errorf("Expected a status change warning, got logs: %s", logs)
+			}
+			if !strings.Contains(warning, tt.wantLog) {
+				t.Errorf("Expected the warning with %s, got %s", tt.wantLog, warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package grpchealth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// dialRelay connects to the target through a relay.
+//
+// The relay must speak the HTTP CONNECT protocol (RFC 9110 Section 9.3.6):
+// the client sends "CONNECT <target> HTTP/1.1" and the relay responds with
+// a 2xx status, then forwards the raw bytes between the client and the target.
+func dialRelay(ctx context.Context, relay, target string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", relay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to relay %s: %w", relay, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT request to relay %s: %w", relay, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from relay %s: %w", relay, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		conn.Close()
+		return nil, fmt.Errorf("relay %s refused to connect to %s: %s", relay, target, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// the target may have already sent data after the CONNECT response
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn that reads from a buffered reader first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,56 @@
+package grpchealth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const selfTestBufSize = 1024 * 1024
+
+type CLISelfTest struct {
+	Service string `help:"Service name to check health status" default:"" short:"s"`
+}
+
+// runSelfTest runs the server and the client over an in-memory listener without opening any real ports
+func runSelfTest(ctx context.Context, opt CLISelfTest) error {
+	lis := bufconn.Listen(selfTestBufSize)
+	defer lis.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	slog.Info("Starting self-test over in-memory connection")
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- serve(ctx, CLIServer{Address: "bufconn"}, serverListener{Listener: lis})
+	}()
+
+	r := manual.NewBuilderWithScheme("bufconn")
+	r.InitialState(resolver.State{
+		Addresses: []resolver.Address{{Addr: "selftest"}},
+	})
+	clientErr := runClient(ctx, CLIClient{
+		Address:   "bufconn:///selftest",
+		Service:   opt.Service,
+		Resolvers: []resolver.Builder{r},
+		ContextDialer: func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		},
+	})
+
+	cancel()
+	if err := <-errCh; err != nil {
+		return fmt.Errorf("self-test server failed: %w", err)
+	}
+	if clientErr != nil {
+		return fmt.Errorf("self-test failed: %w", clientErr)
+	}
+	slog.Info("Self-test passed")
+	return nil
+}
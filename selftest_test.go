@@ -0,0 +1,37 @@
+package grpchealth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     CLISelfTest
+		wantErr bool
+	}{
+		{
+			name: "default service",
+			opt:  CLISelfTest{},
+		},
+		{
+			name:    "unknown service",
+			opt:     CLISelfTest{Service: "unknown"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := runSelfTest(ctx, tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runSelfTest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
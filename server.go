@@ -3,76 +3,173 @@ package grpchealth
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 type CLIServer struct {
-	Address  string `help:"gRPC server address (e.g., :50051 or unix:///tmp/grpc.sock)" arg:"" required:""`
-	CertFile string `help:"Path to the server certificate file" short:"c"`
-	KeyFile  string `help:"Path to the server key file" short:"k"`
+	Address   string `help:"gRPC server address (e.g., :50051 or unix:///tmp/grpc.sock)" arg:"" required:""`
+	CertFile  string `help:"Path to the server certificate file" short:"c"`
+	KeyFile   string `help:"Path to the server key file" short:"k"`
+	KeyPKCS11 string `help:"PKCS#11 URI of the server key in a token or HSM instead of --key-file (requires a binary built with -tags pkcs11)" name:"key-pkcs11" placeholder:"URI"`
+	ClientCA  string `help:"Path to the CA certificate file to require and verify client certificates (mutual TLS, TCP only)"`
+	ClientCRL string `help:"Path to a certificate revocation list (PEM or DER) signed by the client CA to reject revoked client certificates" name:"client-crl"`
+
+	CertReloadInterval time.Duration `help:"Check the certificate and key files for changes at this interval and reload them without restart (0 to disable)" default:"0"`
+
+	FlipAfter    int           `help:"Flip the default service to NOT_SERVING after serving N successful checks (0 to disable)" default:"0"`
+	FlipCooldown time.Duration `help:"Duration to keep the flipped NOT_SERVING status before returning to SERVING" default:"10s"`
+
+	WarmupDuration  time.Duration `help:"Report NOT_SERVING for this duration after start before switching to SERVING"`
+	PlaintextAddr   string        `help:"Additional address to serve the same health status without TLS (e.g., 127.0.0.1:50052)"`
+	ListenTimeout   time.Duration `help:"Fail if the server has not started serving within this duration (0 to disable)"`
+	ShutdownTimeout time.Duration `help:"Force closing the connections if the graceful shutdown does not finish within this duration (0 to wait forever)" default:"10s"`
+
+	SlowRequestThreshold time.Duration `help:"Warn if handling a request takes longer than this duration (0 to disable)"`
+	LogDeadline          bool          `help:"Log the remaining deadline of each incoming request (warns if the client set none)"`
+	Reflection           bool          `help:"Register the server reflection service for tools like grpcurl"`
+
+	Services []string `help:"Named service to register with its initial status, SERVING, NOT_SERVING or UNKNOWN (repeatable)" name:"service" placeholder:"NAME=STATUS" sep:"none"`
+	Config   string   `help:"Path to a YAML or JSON file listing the named services and their statuses (reloaded on SIGHUP)" type:"existingfile"`
+
+	// statuses are the parsed initial statuses of the named services
+	statuses map[string]grpc_health_v1.HealthCheckResponse_ServingStatus `kong:"-"`
 }
 
+// loadX509KeyPair is replaceable for testing
+var loadX509KeyPair = tls.LoadX509KeyPair
+
+// errListenTimeout is the cancel cause when the server does not start serving within --listen-timeout
+var errListenTimeout = errors.New("server did not start serving in time")
+
 func runServer(ctx context.Context, opt CLIServer) error {
-	var lis net.Listener
-	var err error
-	var network, address string
-	
-	// Check if address is Unix Domain Socket
-	if isUnixSocket(opt.Address) {
-		network = "unix"
-		address = parseUnixSocketPath(opt.Address)
-		// Remove existing socket file if it exists
-		if err := os.RemoveAll(address); err != nil {
-			slog.Warn("Failed to remove existing socket file", "path", address, "error", err)
-		}
-		lis, err = net.Listen(network, address)
-		if err != nil {
-			return fmt.Errorf("failed to listen on unix socket: %w", err)
-		}
-		// Cleanup socket file on exit
-		defer func() {
-			if err := os.RemoveAll(address); err != nil {
-				slog.Warn("Failed to cleanup socket file", "path", address, "error", err)
-			}
-		}()
-	} else {
-		network = "tcp"
-		address = opt.Address
-		lis, err = net.Listen(network, address)
-		if err != nil {
-			return fmt.Errorf("failed to listen: %w", err)
+	if opt.ListenTimeout <= 0 {
+		return startServer(ctx, opt, nil)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	watchdog := time.AfterFunc(opt.ListenTimeout, func() {
+		cancel(fmt.Errorf("%w: not serving after %s", errListenTimeout, opt.ListenTimeout))
+	})
+	defer watchdog.Stop()
+
+	// Loading the certificate or binding the address does not check ctx and may hang,
+	// so the startup runs aside and is abandoned when the watchdog fires.
+	// It closes its listeners when it returns late.
+	done := make(chan error, 1)
+	go func() {
+		done <- startServer(ctx, opt, func() { watchdog.Stop() })
+	}()
+	select {
+	case err := <-done:
+		if cause := context.Cause(ctx); errors.Is(cause, errListenTimeout) {
+			return cause
 		}
+		return err
+	case <-ctx.Done():
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, errListenTimeout) {
+		return cause
+	}
+	return <-done
+}
+
+// startServer listens on the addresses and serves until ctx is done.
+// started is called when all the listeners begin accepting connections.
+func startServer(ctx context.Context, opt CLIServer, started func()) error {
+	if opt.KeyFile != "" && opt.KeyPKCS11 != "" {
+		return fmt.Errorf("--key-file and --key-pkcs11 are exclusive")
+	}
+	if opt.KeyPKCS11 != "" && opt.CertFile == "" {
+		return fmt.Errorf("--key-pkcs11 requires --cert-file")
+	}
+	if opt.ClientCA != "" && (opt.CertFile == "" || (opt.KeyFile == "" && opt.KeyPKCS11 == "")) {
+		return fmt.Errorf("--client-ca requires --cert-file and --key-file or --key-pkcs11")
+	}
+	if opt.ClientCRL != "" && opt.ClientCA == "" {
+		return fmt.Errorf("--client-crl requires --client-ca")
 	}
+	statuses, err := serviceStatuses(opt)
+	if err != nil {
+		return err
+	}
+	opt.statuses = statuses
+	lis, cleanup, err := listen(opt.Address)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	network := lis.Addr().Network()
 	var opts []grpc.ServerOption
-	
+
 	// TLS is not applicable for Unix Domain Sockets
 	if network == "unix" {
 		slog.Info("Starting gRPC server on Unix Domain Socket",
 			"address", opt.Address,
-			"socket_path", address,
+			"socket_path", lis.Addr().String(),
 		)
-	} else if opt.CertFile != "" && opt.KeyFile != "" {
+		if opt.ClientCA != "" {
+			slog.Warn("--client-ca is ignored for Unix Domain Sockets")
+		}
+	} else if opt.CertFile != "" && (opt.KeyFile != "" || opt.KeyPKCS11 != "") {
 		// TLS設定 (TCP only)
-		cert, err := tls.LoadX509KeyPair(opt.CertFile, opt.KeyFile)
+		certs, err := newServerCertManager(opt)
 		if err != nil {
-			return fmt.Errorf("failed to load key pair: %w", err)
+			return err
 		}
-		creds := credentials.NewTLS(&tls.Config{
-			Certificates: []tls.Certificate{cert},
-		})
-		opts = append(opts, grpc.Creds(creds))
+		if opt.CertReloadInterval > 0 {
+			slog.Info("Watching the certificate for changes", "interval", opt.CertReloadInterval)
+			watchCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go certs.watch(watchCtx, opt.CertReloadInterval)
+		}
+		tlsConfig := &tls.Config{
+			GetCertificate: certs.getCertificate,
+		}
+		if opt.ClientCA != "" {
+			pool, err := loadCertPool(opt.ClientCA)
+			if err != nil {
+				return err
+			}
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		if opt.ClientCRL != "" {
+			crl, err := loadCRL(opt.ClientCRL)
+			if err != nil {
+				return err
+			}
+			if err := checkCRLIssuer(crl, opt.ClientCA); err != nil {
+				return err
+			}
+			tlsConfig.VerifyPeerCertificate = verifyNotRevoked(crl)
+			slog.Info("Rejecting revoked client certificates",
+				"clientCRL", opt.ClientCRL,
+				"revoked", len(crl.RevokedCertificateEntries),
+			)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 		slog.Info("Starting gRPC server with TLS",
 			"address", opt.Address,
 			"certFile", opt.CertFile,
 			"keyFile", opt.KeyFile,
+			"keyPKCS11", opt.KeyPKCS11 != "",
+			"clientCA", opt.ClientCA,
 		)
 	} else {
 		slog.Info("Starting gRPC server without TLS",
@@ -80,22 +177,261 @@ func runServer(ctx context.Context, opt CLIServer) error {
 		)
 	}
 
-	sv := grpc.NewServer(opts...)
+	if network == "tcp" {
+		logReachableAddresses(lis.Addr())
+	}
+	listeners := []serverListener{{Listener: lis, opts: opts}}
 
-	// register health check service
-	healthServer := health.NewServer()
-	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
-	grpc_health_v1.RegisterHealthServer(sv, healthServer)
+	if opt.PlaintextAddr != "" {
+		plis, cleanup, err := listen(opt.PlaintextAddr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		slog.Info("Starting gRPC server without TLS",
+			"address", opt.PlaintextAddr,
+		)
+		listeners = append(listeners, serverListener{Listener: plis})
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if started != nil {
+		listeners = notifyAccept(listeners, started)
+	}
+
+	return serve(ctx, opt, listeners...)
+}
 
+// acceptNotifier calls the notify function on the first Accept
+type acceptNotifier struct {
+	net.Listener
+	once   sync.Once
+	notify func()
+}
+
+func (l *acceptNotifier) Accept() (net.Conn, error) {
+	l.once.Do(l.notify)
+	return l.Listener.Accept()
+}
+
+// notifyAccept wraps the listeners to call fn once all of them have started accepting
+func notifyAccept(listeners []serverListener, fn func()) []serverListener {
+	var remaining atomic.Int32
+	remaining.Store(int32(len(listeners)))
+	notify := func() {
+		if remaining.Add(-1) == 0 {
+			fn()
+		}
+	}
+	wrapped := make([]serverListener, 0, len(listeners))
+	for _, l := range listeners {
+		wrapped = append(wrapped, serverListener{
+			Listener: &acceptNotifier{Listener: l.Listener, notify: notify},
+			opts:     l.opts,
+		})
+	}
+	return wrapped
+}
+
+// listen listens on the address and returns a function to close the listener and cleanup the unix socket file
+func listen(addr string) (net.Listener, func(), error) {
+	network, address, err := resolveTarget(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if network == "tcp" {
+		// The server listens on a plain address (e.g., localhost:50051, :50051, [::1]:50051)
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, nil, fmt.Errorf("invalid address %q: %w", address, err)
+		}
+	}
+	socketFile := network == "unix" && !isAbstractSocket(address)
+	if socketFile {
+		// Remove existing socket file if it exists
+		if err := os.RemoveAll(address); err != nil {
+			slog.Warn("Failed to remove existing socket file", "path", address, "error", err)
+		}
+	}
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		if network == "unix" {
+			return nil, nil, fmt.Errorf("failed to listen on unix socket: %w", err)
+		}
+		return nil, nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	cleanup := func() {
+		// the server has already closed the listener unless it returned before serving
+		lis.Close()
+		if !socketFile {
+			return
+		}
+		// Cleanup socket file on exit
+		if err := os.RemoveAll(address); err != nil {
+			slog.Warn("Failed to cleanup socket file", "path", address, "error", err)
+		}
+	}
+	return lis, cleanup, nil
+}
+
+// logReachableAddresses logs the concrete addresses of local interfaces when bound to a wildcard address
+func logReachableAddresses(addr net.Addr) {
+	addrs, err := reachableAddresses(addr)
+	if err != nil {
+		slog.Warn("Failed to enumerate interface addresses", "error", err)
+		return
+	}
+	if len(addrs) > 0 {
+		slog.Info("Server is reachable on local interface addresses",
+			"listen", addr.String(),
+			"addresses", addrs,
+		)
+	}
+}
+
+// reachableAddresses returns host:port pairs of local interfaces for a wildcard TCP address.
+// It returns nil if the address is bound to a specific IP.
+func reachableAddresses(addr net.Addr) ([]string, error) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return nil, nil
+	}
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(tcpAddr.Port)
+	var addrs []string
+	for _, ifAddr := range ifAddrs {
+		ipNet, ok := ifAddr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(ipNet.IP.String(), port))
+	}
+	return addrs, nil
+}
+
+// serverListener is a listener with its own server options (e.g. TLS credentials)
+type serverListener struct {
+	net.Listener
+	opts []grpc.ServerOption
+}
+
+// stopServer stops the server gracefully, and forcibly closes the connections
+// if open streams (e.g. Watch) do not finish within the timeout (0 for no timeout)
+func stopServer(sv *grpc.Server, address string, timeout time.Duration) {
+	done := make(chan struct{})
 	go func() {
-		<-ctx.Done()
-		slog.Info("Stopping gRPC server")
 		sv.GracefulStop()
+		close(done)
 	}()
-
-	if err := sv.Serve(lis); err != nil {
-		return fmt.Errorf("failed to serve: %w", err)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case <-done:
+		slog.Info("gRPC server stopped gracefully", "address", address)
+	case <-expired:
+		slog.Warn("Graceful shutdown timed out, forcing stop", "address", address, "timeout", timeout)
+		sv.Stop()
+		<-done
 	}
-	return nil
 }
 
+// serve runs the health check server on the listeners until ctx is done.
+// All listeners share the same health status.
+func serve(ctx context.Context, opt CLIServer, listeners ...serverListener) error {
+	healthServer := health.NewServer()
+	if opt.WarmupDuration > 0 {
+		healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		slog.Info("Warming up", "duration", opt.WarmupDuration)
+		warmup := time.AfterFunc(opt.WarmupDuration, func() {
+			healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+			slog.Info("Warmup completed, now SERVING")
+		})
+		defer warmup.Stop()
+	} else {
+		healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+	for _, name := range slices.Sorted(maps.Keys(opt.statuses)) {
+		st := opt.statuses[name]
+		healthServer.SetServingStatus(name, st)
+		slog.Info("Registered service", "service", name, "status", st.String())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opt.Config != "" {
+		go reloadConfigOnSignal(ctx, opt, healthServer)
+	}
+	go flipStatusOnSignal(ctx, healthServer)
+
+	// recovery must be the outermost interceptor to catch panics in the others
+	interceptors := []grpc.UnaryServerInterceptor{recoveryUnaryInterceptor}
+	if opt.LogDeadline {
+		interceptors = append(interceptors, deadlineLoggingUnaryInterceptor)
+	}
+	if opt.SlowRequestThreshold > 0 {
+		detector := newSlowRequestDetector(opt.SlowRequestThreshold)
+		interceptors = append(interceptors, detector.unaryInterceptor)
+		defer func() {
+			slog.Info("Slow request summary", "slow_requests", detector.slowRequests(), "threshold", opt.SlowRequestThreshold)
+		}()
+	}
+	if opt.FlipAfter > 0 {
+		flipper := newStatusFlipper(healthServer, opt.FlipAfter, opt.FlipCooldown)
+		interceptors = append(interceptors, flipper.unaryInterceptor)
+	}
+	common := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(recoveryStreamInterceptor),
+	}
+
+	if opt.Reflection {
+		slog.Info("Server reflection is enabled")
+	}
+	errCh := make(chan error, len(listeners))
+	var stopped sync.WaitGroup
+	for _, l := range listeners {
+		sv := grpc.NewServer(slices.Concat(common, l.opts)...)
+
+		// register health check service
+		grpc_health_v1.RegisterHealthServer(sv, healthServer)
+		if opt.Reflection {
+			reflection.Register(sv)
+		}
+
+		stopped.Add(1)
+		go func() {
+			defer stopped.Done()
+			<-ctx.Done()
+			slog.Info("Stopping gRPC server", "address", l.Addr().String())
+			stopServer(sv, l.Addr().String(), opt.ShutdownTimeout)
+		}()
+		go func() {
+			errCh <- sv.Serve(l)
+		}()
+	}
+
+	// stop all servers if any of them fails
+	var serveErr error
+	for range listeners {
+		err := <-errCh
+		if errors.Is(err, grpc.ErrServerStopped) {
+			// stopped by the shutdown before Serve started
+			continue
+		}
+		if err != nil && serveErr == nil {
+			serveErr = fmt.Errorf("failed to serve: %w", err)
+			cancel()
+		}
+	}
+	// wait for the shutdown to be logged
+	cancel()
+	stopped.Wait()
+	return serveErr
+}
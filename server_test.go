@@ -1,6 +1,7 @@
 package grpchealth
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -8,17 +9,25 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"log/slog"
 	"math/big"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
 )
 
 func TestRunServer(t *testing.T) {
@@ -188,7 +197,7 @@ func createTempCertFiles(t *testing.T) (certFile, keyFile string, cleanup func()
 		NotBefore:   time.Now(),
 		NotAfter:    time.Now().Add(365 * 24 * time.Hour),
 		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
 		DNSNames:    []string{"localhost"},
 	}
@@ -246,7 +255,7 @@ func TestRunServerUnixSocket(t *testing.T) {
 	// Create temporary socket path
 	tempDir := t.TempDir()
 	socketPath := filepath.Join(tempDir, "test.sock")
-	
+
 	opt := CLIServer{
 		Address: "unix:" + socketPath,
 	}
@@ -376,3 +385,466 @@ func (h *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthChec
 		Status: grpc_health_v1.HealthCheckResponse_SERVING,
 	}, nil
 }
+
+func TestRunServerFlipAfter(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	opt := CLIServer{
+		Address:      address,
+		FlipAfter:    2,
+		FlipCooldown: 300 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, opt)
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	check := func() grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Health check failed: %v", err)
+		}
+		return resp.Status
+	}
+
+	for i, want := range []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	} {
+		if got := check(); got != want {
+			t.Errorf("check %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Wait for the cooldown
+	time.Sleep(500 * time.Millisecond)
+	if got := check(); got != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("Expected SERVING after cooldown, got %v", got)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("runServer() error = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Server did not shut down gracefully")
+	}
+}
+
+func TestReachableAddresses(t *testing.T) {
+	addrs, err := reachableAddresses(&net.TCPAddr{IP: net.IPv6unspecified, Port: 50051})
+	if err != nil {
+		t.Fatalf("reachableAddresses() error = %v", err)
+	}
+	found := false
+	for _, a := range addrs {
+		if a == "127.0.0.1:50051" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected 127.0.0.1:50051 in %v", addrs)
+	}
+
+	addrs, err = reachableAddresses(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50051})
+	if err != nil {
+		t.Fatalf("reachableAddresses() error = %v", err)
+	}
+	if addrs != nil {
+		t.Errorf("Expected nil for a specific address, got %v", addrs)
+	}
+}
+
+func TestRunServerWarmup(t *testing.T) {
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	opt := CLIServer{
+		Address:        address,
+		WarmupDuration: 300 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, opt)
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING during warmup, got %v", resp.Status)
+	}
+
+	// Wait for the warmup to complete
+	time.Sleep(400 * time.Millisecond)
+	resp, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("Expected SERVING after warmup, got %v", resp.Status)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("runServer() error = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Server did not shut down gracefully")
+	}
+}
+
+func TestRunServerPlaintextAddr(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	// Get available ports
+	var addresses []string
+	for range 2 {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to create listener: %v", err)
+		}
+		addresses = append(addresses, lis.Addr().String())
+		lis.Close()
+	}
+
+	opt := CLIServer{
+		Address:       addresses[0],
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		PlaintextAddr: addresses[1],
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, opt)
+	}()
+
+	// Give server time to start
+	time.Sleep(200 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		address string
+		creds   credentials.TransportCredentials
+	}{
+		{
+			name:    "TLS address",
+			address: addresses[0],
+			creds:   credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}),
+		},
+		{
+			name:    "plaintext address",
+			address: addresses[1],
+			creds:   insecure.NewCredentials(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := grpc.NewClient(tt.address, grpc.WithTransportCredentials(tt.creds))
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			client := grpc_health_v1.NewHealthClient(conn)
+			resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			if err != nil {
+				t.Fatalf("Health check failed: %v", err)
+			}
+			if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+				t.Errorf("Expected SERVING status, got %v", resp.Status)
+			}
+		})
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("runServer() error = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Server did not shut down gracefully")
+	}
+}
+
+func TestRunServerListenTimeout(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		hang    bool
+		wantErr bool
+	}{
+		{
+			name: "started in time",
+		},
+		{
+			name:    "hanging certificate loading",
+			hang:    true,
+			wantErr: true,
+		},
+	}
+
+	original := loadX509KeyPair
+	defer func() { loadX509KeyPair = original }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loading := make(chan struct{}, 1)
+			release := make(chan struct{})
+			loadX509KeyPair = func(certFile, keyFile string) (tls.Certificate, error) {
+				loading <- struct{}{}
+				if tt.hang {
+					<-release
+				}
+				return original(certFile, keyFile)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := runServer(ctx, CLIServer{
+				Address:       "127.0.0.1:0",
+				CertFile:      certFile,
+				KeyFile:       keyFile,
+				ListenTimeout: 200 * time.Millisecond,
+			})
+			elapsed := time.Since(start)
+			// let the abandoned startup finish
+			<-loading
+			close(release)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, errListenTimeout) {
+				t.Errorf("runServer() error = %v, want errListenTimeout", err)
+			}
+			// A hanging startup is not waited for
+			if tt.wantErr && elapsed > 400*time.Millisecond {
+				t.Errorf("runServer() returned after %v, want about the listen timeout", elapsed)
+			}
+		})
+	}
+}
+
+func TestRunServerCanceled(t *testing.T) {
+	// The server may be stopped before Serve starts, which is a clean shutdown too
+	for _, delay := range []time.Duration{0, time.Millisecond, 50 * time.Millisecond} {
+		t.Run(delay.String(), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runServer(ctx, CLIServer{Address: "127.0.0.1:0"})
+			}()
+			time.Sleep(delay)
+			cancel()
+			select {
+			case err := <-errCh:
+				if err != nil {
+					t.Errorf("runServer() error = %v, want nil", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Error("Server did not shut down")
+			}
+		})
+	}
+}
+
+func TestServeStoppedBeforeServe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := serve(ctx, CLIServer{}, serverListener{Listener: lis}); err != nil {
+		t.Errorf("serve() error = %v, want nil", err)
+	}
+}
+
+func TestRunServerReflection(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(strconv.FormatBool(enabled), func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to create listener: %v", err)
+			}
+			address := lis.Addr().String()
+			lis.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runServer(ctx, CLIServer{Address: address, Reflection: enabled})
+			}()
+
+			// Give server time to start
+			time.Sleep(100 * time.Millisecond)
+
+			conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			if err != nil {
+				t.Fatalf("Failed to open the reflection stream: %v", err)
+			}
+			if err := stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+				MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+			}); err != nil {
+				t.Fatalf("Failed to send the request: %v", err)
+			}
+			resp, err := stream.Recv()
+			if !enabled {
+				if status.Code(err) != codes.Unimplemented {
+					t.Errorf("Expected Unimplemented without --reflection, got %v", err)
+				}
+				cancel()
+				<-errCh
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to list services: %v", err)
+			}
+			var services []string
+			for _, s := range resp.GetListServicesResponse().GetService() {
+				services = append(services, s.GetName())
+			}
+			if !slices.Contains(services, grpc_health_v1.Health_ServiceDesc.ServiceName) {
+				t.Errorf("Listed services = %v, want the health service", services)
+			}
+			stream.CloseSend()
+
+			cancel()
+			if err := <-errCh; err != nil {
+				t.Errorf("runServer() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRunServerShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		watch   bool
+		wantLog string
+	}{
+		{name: "forced", timeout: 200 * time.Millisecond, watch: true, wantLog: "forcing stop"},
+		{name: "graceful", timeout: 200 * time.Millisecond, wantLog: "stopped gracefully"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			originalLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(originalLogger)
+
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to create listener: %v", err)
+			}
+			address := lis.Addr().String()
+			lis.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runServer(ctx, CLIServer{Address: address, ShutdownTimeout: tt.timeout})
+			}()
+
+			// Give server time to start
+			time.Sleep(100 * time.Millisecond)
+
+			if tt.watch {
+				// Hold a Watch stream open, which blocks the graceful shutdown
+				conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+				if err != nil {
+					t.Fatalf("Failed to connect: %v", err)
+				}
+				defer conn.Close()
+				stream, err := grpc_health_v1.NewHealthClient(conn).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+				if err != nil {
+					t.Fatalf("Failed to watch: %v", err)
+				}
+				if _, err := stream.Recv(); err != nil {
+					t.Fatalf("Failed to receive: %v", err)
+				}
+			}
+
+			start := time.Now()
+			cancel()
+			select {
+			case err := <-errCh:
+				if err != nil {
+					t.Errorf("runServer() error = %v", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Server did not shut down")
+			}
+			if elapsed := time.Since(start); tt.watch && elapsed < tt.timeout {
+				t.Errorf("Server stopped in %s, before the shutdown timeout %s", elapsed, tt.timeout)
+			}
+			if !strings.Contains(buf.String(), tt.wantLog) {
+				t.Errorf("Log does not contain %q: %s", tt.wantLog, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package grpchealth
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// servingStatuses are the statuses a service can be declared with.
+// SERVICE_UNKNOWN is reserved for the Watch of an unregistered service.
+var servingStatuses = []grpc_health_v1.HealthCheckResponse_ServingStatus{
+	grpc_health_v1.HealthCheckResponse_UNKNOWN,
+	grpc_health_v1.HealthCheckResponse_SERVING,
+	grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+}
+
+// parseServingStatus parses a status token such as SERVING (case-insensitive)
+func parseServingStatus(s string) (grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	for _, st := range servingStatuses {
+		if strings.EqualFold(s, st.String()) {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid status %q: must be one of SERVING, NOT_SERVING, UNKNOWN", s)
+}
+
+// parseServiceStatuses parses the name=STATUS pairs of --service.
+// The default service "" is controlled by the other flags, so the name is required.
+func parseServiceStatuses(specs []string) (map[string]grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	statuses := make(map[string]grpc_health_v1.HealthCheckResponse_ServingStatus, len(specs))
+	for _, spec := range specs {
+		name, token, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid service %q: must be in the form name=STATUS", spec)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid service %q: name is required", spec)
+		}
+		st, err := parseServingStatus(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service %q: %w", spec, err)
+		}
+		statuses[name] = st
+	}
+	return statuses, nil
+}
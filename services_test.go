@@ -0,0 +1,134 @@
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseServiceStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]grpc_health_v1.HealthCheckResponse_ServingStatus
+		wantErr bool
+	}{
+		{name: "none", want: map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{}},
+		{
+			name:  "statuses",
+			specs: []string{"users=SERVING", "orders=not_serving", "legacy=UNKNOWN"},
+			want: map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{
+				"users":  grpc_health_v1.HealthCheckResponse_SERVING,
+				"orders": grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+				"legacy": grpc_health_v1.HealthCheckResponse_UNKNOWN,
+			},
+		},
+		{
+			name:  "later one wins",
+			specs: []string{"users=SERVING", "users=NOT_SERVING"},
+			want:  map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{"users": grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		},
+		{name: "typo", specs: []string{"users=SERVNG"}, wantErr: true},
+		{name: "service unknown is reserved", specs: []string{"users=SERVICE_UNKNOWN"}, wantErr: true},
+		{name: "without status", specs: []string{"users"}, wantErr: true},
+		{name: "without name", specs: []string{"=SERVING"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseServiceStatuses(tt.specs)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for name, st := range tt.want {
+				if got[name] != st {
+					t.Errorf("status of %q = %v, want %v", name, got[name], st)
+				}
+			}
+		})
+	}
+}
+
+func TestRunServerServices(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, CLIServer{
+			Address:  address,
+			Services: []string{"users=SERVING", "orders=NOT_SERVING"},
+		})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	tests := []struct {
+		service  string
+		want     grpc_health_v1.HealthCheckResponse_ServingStatus
+		wantCode codes.Code
+	}{
+		{service: "", want: grpc_health_v1.HealthCheckResponse_SERVING},
+		{service: "users", want: grpc_health_v1.HealthCheckResponse_SERVING},
+		{service: "orders", want: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{service: "unknown", wantCode: codes.NotFound},
+	}
+	for _, tt := range tests {
+		resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: tt.service})
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("Check(%q) error = %v, want code %v", tt.service, err, tt.wantCode)
+			continue
+		}
+		if err == nil && resp.Status != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.service, resp.Status, tt.want)
+		}
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+}
+
+func TestRunServerInvalidService(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := runServer(ctx, CLIServer{
+		Address:  "127.0.0.1:0",
+		Services: []string{"users=SERVNG"},
+	})
+	if err == nil {
+		t.Error("runServer() expected error, got nil")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package grpchealth
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// reloadSignals are the signals to reload the server config file
+func reloadSignals() []os.Signal {
+	return []os.Signal{unix.SIGHUP}
+}
+
+// statusSignals are the signals to set the status of the default service
+func statusSignals() map[os.Signal]grpc_health_v1.HealthCheckResponse_ServingStatus {
+	return map[os.Signal]grpc_health_v1.HealthCheckResponse_ServingStatus{
+		unix.SIGUSR1: grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+		unix.SIGUSR2: grpc_health_v1.HealthCheckResponse_SERVING,
+	}
+}
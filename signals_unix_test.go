@@ -0,0 +1,146 @@
+//go:build !windows
+
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRunServerReloadConfig(t *testing.T) {
+	config := writeConfigFile(t, "config.yaml", `services:
+  - name: users
+    status: SERVING
+  - name: orders
+    status: SERVING
+`)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, CLIServer{Address: address, Config: config})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+	check := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q) failed: %v", service, err)
+		}
+		return resp.Status
+	}
+	reload := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(config, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write the config file: %v", err)
+		}
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatalf("Failed to send SIGHUP: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if got := check("users"); got != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("users = %v, want SERVING", got)
+	}
+
+	// users changes, and orders is removed
+	reload("services:\n  - name: users\n    status: NOT_SERVING\n")
+	if got := check("users"); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("users after reload = %v, want NOT_SERVING", got)
+	}
+	if got := check("orders"); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("removed orders after reload = %v, want NOT_SERVING", got)
+	}
+
+	// an invalid config keeps the current statuses
+	reload("services:\n  - name: users\n    status: SERVNG\n")
+	if got := check("users"); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("users after invalid reload = %v, want NOT_SERVING", got)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+}
+
+func TestRunServerStatusSignals(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, CLIServer{Address: address})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	tests := []struct {
+		signal syscall.Signal
+		want   grpc_health_v1.HealthCheckResponse_ServingStatus
+	}{
+		{signal: syscall.SIGUSR1, want: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{signal: syscall.SIGUSR1, want: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{signal: syscall.SIGUSR2, want: grpc_health_v1.HealthCheckResponse_SERVING},
+	}
+	for _, tt := range tests {
+		if err := syscall.Kill(os.Getpid(), tt.signal); err != nil {
+			t.Fatalf("Failed to send %s: %v", tt.signal, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Health check failed: %v", err)
+		}
+		if resp.Status != tt.want {
+			t.Errorf("status after %s = %v, want %v", tt.signal, resp.Status, tt.want)
+		}
+	}
+
+	// The shutdown by the context still works
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package grpchealth
+
+import (
+	"os"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// reloadSignals are the signals to reload the server config file. Windows has no SIGHUP.
+func reloadSignals() []os.Signal {
+	return nil
+}
+
+// statusSignals are the signals to set the status of the default service. Windows has no SIGUSR1 and SIGUSR2.
+func statusSignals() map[os.Signal]grpc_health_v1.HealthCheckResponse_ServingStatus {
+	return nil
+}
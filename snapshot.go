@@ -0,0 +1,87 @@
+package grpchealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type CLISnapshot struct {
+	Address    string        `help:"gRPC server address (e.g., localhost:50051 or unix:///tmp/grpc.sock)" arg:"" required:""`
+	File       string        `help:"File to write the snapshot JSON to" short:"f" required:"" placeholder:"FILE"`
+	TLS        bool          `help:"Use TLS for connection" short:"t"`
+	Insecure   bool          `help:"Use insecure connection" short:"k"`
+	Timeout    time.Duration `help:"Timeout for the List request (0 for no timeout)" default:"10s"`
+	ServerName string        `help:"Server name to verify the server certificate against, instead of the host of the address"`
+	CAFile     string        `help:"Path to a PEM bundle of CA certificates to verify the server certificate" name:"ca-file"`
+	Cert       string        `help:"Path to the client certificate file for mutual TLS"`
+	Key        string        `help:"Path to the client key file for mutual TLS"`
+	Headers    []string      `help:"Metadata header sent with the request (repeatable)" name:"header" short:"H" placeholder:"KEY=VALUE" sep:"none"`
+	Token      string        `help:"Bearer token sent in the authorization metadata"`
+	TokenFile  string        `help:"Path to a file containing the bearer token"`
+}
+
+// healthSnapshot is the status of every service known to the server at a point in time.
+// The services are keyed by name ("" for the overall server) so that two snapshots diff cleanly.
+type healthSnapshot struct {
+	Address  string            `json:"address"`
+	TakenAt  time.Time         `json:"taken_at"`
+	Services map[string]string `json:"services"`
+}
+
+// runSnapshot lists the status of every service with the List RPC and writes them to the file
+func runSnapshot(ctx context.Context, opt CLISnapshot) error {
+	snapshot := &healthSnapshot{Address: opt.Address}
+	err := checkHealth(ctx, CLIClient{
+		Address:    opt.Address,
+		TLS:        opt.TLS,
+		Insecure:   opt.Insecure,
+		Timeout:    opt.Timeout,
+		ServerName: opt.ServerName,
+		CAFile:     opt.CAFile,
+		Cert:       opt.Cert,
+		Key:        opt.Key,
+		Headers:    opt.Headers,
+		Token:      opt.Token,
+		TokenFile:  opt.TokenFile,
+		UserAgent:  "grpchealth/" + buildVersion(),
+		snapshot:   snapshot,
+	}, newEventEmitter(CLIClient{}))
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the snapshot: %w", err)
+	}
+	if err := os.WriteFile(opt.File, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	slog.Info("Saved health snapshot", "file", opt.File, "services", len(snapshot.Services))
+	return nil
+}
+
+// listHealth fills the snapshot with the statuses returned by the List RPC
+func listHealth(ctx context.Context, client grpc_health_v1.HealthClient, snapshot *healthSnapshot) error {
+	slog.Info("Sending health list request", "address", snapshot.Address)
+	snapshot.TakenAt = time.Now()
+	resp, err := client.List(ctx, &grpc_health_v1.HealthListRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return fmt.Errorf("the server does not support the List RPC: %w", err)
+		}
+		return fmt.Errorf("health list request failed: %w", err)
+	}
+	snapshot.Services = make(map[string]string, len(resp.GetStatuses()))
+	for name, st := range resp.GetStatuses() {
+		snapshot.Services[name] = st.GetStatus().String()
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package grpchealth
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// checkOnlyHealthServer implements Check but not List, like servers built before List existed
+type checkOnlyHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (checkOnlyHealthServer) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func TestRunSnapshot(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("api", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("worker", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := runSnapshot(ctx, CLISnapshot{Address: lis.Addr().String(), File: file, Timeout: time.Second}); err != nil {
+		t.Fatalf("runSnapshot() error = %v", err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read the snapshot: %v", err)
+	}
+	var got healthSnapshot
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Failed to decode the snapshot: %v\n%s", err, b)
+	}
+	if got.Address != lis.Addr().String() {
+		t.Errorf("Address = %q, want %q", got.Address, lis.Addr().String())
+	}
+	if got.TakenAt.IsZero() {
+		t.Error("TakenAt is zero")
+	}
+	want := map[string]string{"": "SERVING", "api": "SERVING", "worker": "NOT_SERVING"}
+	if len(got.Services) != len(want) {
+		t.Errorf("Services = %v, want %v", got.Services, want)
+	}
+	for name, st := range want {
+		if got.Services[name] != st {
+			t.Errorf("Services[%q] = %q, want %q", name, got.Services[name], st)
+		}
+	}
+}
+
+func TestRunSnapshotErrors(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, checkOnlyHealthServer{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name string
+		opt  CLISnapshot
+	}{
+		{name: "List not implemented", opt: CLISnapshot{Address: lis.Addr().String()}},
+		{name: "unwritable file", opt: CLISnapshot{Address: startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING), File: "/nonexistent/snapshot.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			opt := tt.opt
+			if opt.File == "" {
+				opt.File = filepath.Join(t.TempDir(), "snapshot.json")
+			}
+			opt.Timeout = time.Second
+			if err := runSnapshot(ctx, opt); err == nil {
+				t.Error("runSnapshot() expected error, got nil")
+			}
+			if _, err := os.Stat(opt.File); err == nil {
+				t.Error("Expected no snapshot file on error")
+			}
+		})
+	}
+}
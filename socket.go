@@ -1,26 +1,61 @@
 package grpchealth
 
-import "strings"
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
 
-// isUnixSocket checks if the given address is a Unix Domain Socket
-func isUnixSocket(address string) bool {
-	// unix: prefix (e.g., unix:/tmp/grpc.sock)
-	if strings.HasPrefix(address, "unix:") {
-		return true
+// resolveTarget classifies the address and returns the network and the cleaned address
+//
+// Supported forms:
+//   - unix:/path, unix:///path, unix://path and bare /path (Unix Domain Socket)
+//   - unix-abstract:name and @name (Linux abstract Unix Domain Socket)
+//   - anything else is returned unchanged as a TCP gRPC target (e.g., host:port, dns:///host:port,
+//     passthrough:///host:port), left to grpc.NewClient to parse on the client and to listen on the server
+func resolveTarget(address string) (network, addr string, err error) {
+	switch {
+	case strings.HasPrefix(address, "unix-abstract:"):
+		name := strings.TrimPrefix(address, "unix-abstract:")
+		return resolveAbstract(address, name)
+	case strings.HasPrefix(address, "unix://"):
+		// unix:///path (canonical gRPC form) or unix://path
+		return resolveUnix(address, strings.TrimPrefix(address, "unix://"))
+	case strings.HasPrefix(address, "unix:"):
+		return resolveUnix(address, strings.TrimPrefix(address, "unix:"))
+	case strings.HasPrefix(address, "@"):
+		return resolveAbstract(address, strings.TrimPrefix(address, "@"))
+	case strings.HasPrefix(address, "/"):
+		return "unix", address, nil
+	case strings.HasPrefix(address, "vsock:"):
+		return "", "", fmt.Errorf("vsock address is not supported: %s", address)
 	}
-	// Absolute path (e.g., /tmp/grpc.sock)
-	if strings.HasPrefix(address, "/") {
-		return true
+
+	return "tcp", address, nil
+}
+
+func resolveUnix(address, path string) (string, string, error) {
+	if path == "" {
+		return "", "", fmt.Errorf("empty unix socket path: %s", address)
+	}
+	if strings.HasPrefix(path, "@") {
+		return resolveAbstract(address, strings.TrimPrefix(path, "@"))
 	}
-	return false
+	return "unix", path, nil
 }
 
-// parseUnixSocketPath extracts the socket path from various formats
-func parseUnixSocketPath(address string) string {
-	// Remove unix: prefix if present
-	if strings.HasPrefix(address, "unix:") {
-		return strings.TrimPrefix(address, "unix:")
+func resolveAbstract(address, name string) (string, string, error) {
+	if runtime.GOOS != "linux" {
+		return "", "", fmt.Errorf("abstract unix socket is supported only on linux: %s", address)
 	}
-	// Return as-is for absolute paths
-	return address
-}
\ No newline at end of file
+	if name == "" {
+		return "", "", fmt.Errorf("empty abstract unix socket name: %s", address)
+	}
+	// Go's net package uses a leading '@' for abstract sockets
+	return "unix", "@" + name, nil
+}
+
+// isAbstractSocket checks if the resolved unix socket address is an abstract socket
+func isAbstractSocket(addr string) bool {
+	return strings.HasPrefix(addr, "@")
+}
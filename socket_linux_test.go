@@ -0,0 +1,44 @@
+package grpchealth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunServerAbstractSocket(t *testing.T) {
+	// Abstract names are global to the network namespace, so make it unique per run
+	name := fmt.Sprintf("grpchealth-test-%d", os.Getpid())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx, CLIServer{Address: "unix-abstract:" + name})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	for _, address := range []string{"unix-abstract:" + name, "@" + name, "unix:@" + name} {
+		t.Run(address, func(t *testing.T) {
+			if err := runClient(ctx, CLIClient{Address: address, Timeout: time.Second}); err != nil {
+				t.Errorf("runClient(%q) error = %v", address, err)
+			}
+		})
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+
+	// An abstract socket leaves no file behind
+	for _, file := range []string{name, "@" + name} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("Expected no socket file %s, got %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestResolveTarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		wantNetwork string
+		wantAddr    string
+		wantErr     bool
+		linuxOnly   bool
+	}{
+		{
+			name:        "unix prefix",
+			address:     "unix:/tmp/grpc.sock",
+			wantNetwork: "unix",
+			wantAddr:    "/tmp/grpc.sock",
+		},
+		{
+			name:        "unix prefix with relative path",
+			address:     "unix:grpc.sock",
+			wantNetwork: "unix",
+			wantAddr:    "grpc.sock",
+		},
+		{
+			name:        "unix triple slash",
+			address:     "unix:///tmp/grpc.sock",
+			wantNetwork: "unix",
+			wantAddr:    "/tmp/grpc.sock",
+		},
+		{
+			name:        "unix double slash with relative path",
+			address:     "unix://grpc.sock",
+			wantNetwork: "unix",
+			wantAddr:    "grpc.sock",
+		},
+		{
+			name:    "empty unix double slash path",
+			address: "unix://",
+			wantErr: true,
+		},
+		{
+			name:        "bare absolute path",
+			address:     "/tmp/grpc.sock",
+			wantNetwork: "unix",
+			wantAddr:    "/tmp/grpc.sock",
+		},
+		{
+			name:    "empty unix path",
+			address: "unix:",
+			wantErr: true,
+		},
+		{
+			name:        "unix-abstract prefix",
+			address:     "unix-abstract:grpchealth",
+			wantNetwork: "unix",
+			wantAddr:    "@grpchealth",
+			linuxOnly:   true,
+		},
+		{
+			name:        "at-prefixed abstract name",
+			address:     "@grpchealth",
+			wantNetwork: "unix",
+			wantAddr:    "@grpchealth",
+			linuxOnly:   true,
+		},
+		{
+			name:        "unix prefix with abstract name",
+			address:     "unix:@grpchealth",
+			wantNetwork: "unix",
+			wantAddr:    "@grpchealth",
+			linuxOnly:   true,
+		},
+		{
+			name:        "unix double slash with abstract name",
+			address:     "unix://@grpchealth",
+			wantNetwork: "unix",
+			wantAddr:    "@grpchealth",
+			linuxOnly:   true,
+		},
+		{
+			name:      "empty abstract name",
+			address:   "unix-abstract:",
+			wantErr:   true,
+			linuxOnly: true,
+		},
+		{
+			name:        "tcp host and port",
+			address:     "localhost:50051",
+			wantNetwork: "tcp",
+			wantAddr:    "localhost:50051",
+		},
+		{
+			name:        "tcp port only",
+			address:     ":50051",
+			wantNetwork: "tcp",
+			wantAddr:    ":50051",
+		},
+		{
+			name:        "tcp ipv6",
+			address:     "[::1]:50051",
+			wantNetwork: "tcp",
+			wantAddr:    "[::1]:50051",
+		},
+		{
+			name:        "dns target",
+			address:     "dns:///localhost:50051",
+			wantNetwork: "tcp",
+			wantAddr:    "dns:///localhost:50051",
+		},
+		{
+			name:        "passthrough target",
+			address:     "passthrough:///127.0.0.1:1",
+			wantNetwork: "tcp",
+			wantAddr:    "passthrough:///127.0.0.1:1",
+		},
+		{
+			name:        "host without port is left to gRPC",
+			address:     "localhost",
+			wantNetwork: "tcp",
+			wantAddr:    "localhost",
+		},
+		{
+			name:    "vsock",
+			address: "vsock:3:50051",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.linuxOnly && runtime.GOOS != "linux" {
+				t.Skip("abstract unix socket is supported only on linux")
+			}
+			network, addr, err := resolveTarget(tt.address)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("resolveTarget(%q) expected error, got network=%q addr=%q", tt.address, network, addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveTarget(%q) unexpected error: %v", tt.address, err)
+			}
+			if network != tt.wantNetwork {
+				t.Errorf("network = %q, want %q", network, tt.wantNetwork)
+			}
+			if addr != tt.wantAddr {
+				t.Errorf("addr = %q, want %q", addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestListenInvalidAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+	}{
+		{name: "ipv6 without brackets", address: "::1:50051"},
+		{name: "missing port", address: "invalid-address"},
+		{name: "dns target", address: "dns:///localhost:50051"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis, cleanup, err := listen(tt.address)
+			if err == nil {
+				lis.Close()
+				cleanup()
+				t.Errorf("listen(%q) expected error, got nil", tt.address)
+			}
+		})
+	}
+}
+
+func TestRunClientGRPCTargets(t *testing.T) {
+	address := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+	for _, target := range []string{"dns:///" + address, "passthrough:///" + address} {
+		t.Run(target, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := runClient(ctx, CLIClient{Address: target}); err != nil {
+				t.Errorf("runClient(%q) error = %v", target, err)
+			}
+		})
+	}
+}
+
+func TestRunClientUnixSocketAddressForms(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "grpc.sock")
+	lis, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name    string
+		address string
+	}{
+		{name: "unix prefix", address: "unix:" + socketPath},
+		{name: "unix triple slash", address: "unix://" + socketPath},
+		{name: "bare absolute path", address: socketPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := runClient(ctx, CLIClient{Address: tt.address}); err != nil {
+				t.Errorf("runClient(%q) error = %v", tt.address, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package grpchealth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// lookupSRV is replaceable for testing
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// runSRVClient resolves the address as a DNS SRV name and checks every target
+// in the order of priority and weight
+func runSRVClient(ctx context.Context, opt CLIClient) error {
+	_, records, err := lookupSRV(ctx, "", "", opt.Address)
+	if err != nil {
+		return fmt.Errorf("failed to lookup SRV records for %s: %w", opt.Address, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no SRV records found for %s", opt.Address)
+	}
+	slog.Info("Resolved SRV records", "name", opt.Address, "targets", len(records))
+
+	var errs []error
+	for _, rec := range records {
+		target := net.JoinHostPort(strings.TrimSuffix(rec.Target, "."), strconv.Itoa(int(rec.Port)))
+		o := opt
+		o.Address = target
+		o.SRV = false
+		if err := runClient(ctx, o); err != nil {
+			errs = append(errs, fmt.Errorf("SRV target %s: %w", target, err))
+			slog.Error("SRV target is not healthy",
+				"target", target,
+				"priority", rec.Priority,
+				"weight", rec.Weight,
+				"error", err,
+			)
+			continue
+		}
+		slog.Info("SRV target is healthy",
+			"target", target,
+			"priority", rec.Priority,
+			"weight", rec.Weight,
+		)
+	}
+	return batchError(opt, errs, len(records), "SRV targets")
+}
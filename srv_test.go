@@ -0,0 +1,90 @@
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRunSRVClient(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	port := uint16(lis.Addr().(*net.TCPAddr).Port)
+
+	// Get a port with no server listening
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedPort := uint16(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		records []*net.SRV
+		wantErr bool
+	}{
+		{
+			name: "all targets healthy",
+			records: []*net.SRV{
+				{Target: "127.0.0.1.", Port: port, Priority: 10, Weight: 5},
+			},
+		},
+		{
+			name: "one target down",
+			records: []*net.SRV{
+				{Target: "127.0.0.1.", Port: port, Priority: 10, Weight: 5},
+				{Target: "127.0.0.1.", Port: closedPort, Priority: 20, Weight: 5},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no records",
+			records: nil,
+			wantErr: true,
+		},
+	}
+
+	originalLookupSRV := lookupSRV
+	defer func() { lookupSRV = originalLookupSRV }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				if name != "_grpc._tcp.example.com" {
+					t.Errorf("Unexpected SRV name: %s", name)
+				}
+				return name, tt.records, nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := runClient(ctx, CLIClient{Address: "_grpc._tcp.example.com", SRV: true})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
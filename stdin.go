@@ -0,0 +1,80 @@
+package grpchealth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// stdin is replaceable for testing
+var stdin io.Reader = os.Stdin
+
+// readTargets reads newline-separated targets. Blank lines and lines starting with # are skipped.
+func readTargets(r io.Reader) ([]string, error) {
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read targets from stdin: %w", err)
+	}
+	return targets, nil
+}
+
+// runStdinClient checks every target read from stdin, up to --concurrency targets at the same time
+func runStdinClient(ctx context.Context, opt CLIClient) error {
+	if opt.Interval > 0 {
+		// stdin can be read only once
+		return fmt.Errorf("reading targets from stdin is not supported with --interval")
+	}
+	targets, err := readTargets(stdin)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets read from stdin")
+	}
+	concurrency := max(opt.Concurrency, 1)
+	slog.Info("Read targets from stdin", "targets", len(targets), "concurrency", concurrency)
+
+	// errs is indexed by the target, to keep the order of the input
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, target := range targets {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			o := opt
+			o.Address = target
+			if err := runClient(ctx, o); err != nil {
+				slog.Error("Target is not healthy", "target", target, "error", err)
+				errs[i] = fmt.Errorf("target %s: %w", target, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return batchError(opt, slices.DeleteFunc(errs, func(err error) bool { return err == nil }), len(targets), "targets")
+}
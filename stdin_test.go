@@ -0,0 +1,125 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadTargets(t *testing.T) {
+	got, err := readTargets(strings.NewReader("host1:50051\n\n  host2:50051  \n# comment\nunix:///tmp/grpc.sock\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"host1:50051", "host2:50051", "unix:///tmp/grpc.sock"}
+	if !slices.Equal(got, want) {
+		t.Errorf("readTargets() = %q, want %q", got, want)
+	}
+}
+
+// startHealthServer starts a health server with the status of the default service and returns its address
+func startHealthServer(t *testing.T, st grpc_health_v1.HealthCheckResponse_ServingStatus) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", st)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestRunClientStdin(t *testing.T) {
+	serving := []string{
+		startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING),
+		startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING),
+		startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING),
+	}
+	notServing := startHealthServer(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	tests := []struct {
+		name        string
+		input       string
+		concurrency int
+		wantChecked int
+		wantErr     bool
+	}{
+		{name: "all serving", input: strings.Join(serving, "\n") + "\n", wantChecked: 3},
+		{name: "concurrent", input: strings.Join(serving, "\n"), concurrency: 2, wantChecked: 3},
+		{name: "one not serving", input: strings.Join(append(serving, notServing), "\n"), concurrency: 4, wantChecked: 4, wantErr: true},
+		{name: "no targets", input: "\n# nothing\n", wantErr: true},
+	}
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin = strings.NewReader(tt.input)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			var mu sync.Mutex
+			var checked []string
+			var out bytes.Buffer
+			err := runClient(ctx, CLIClient{
+				Address:     "-",
+				Concurrency: tt.concurrency,
+				Output:      "json",
+				Stdout:      &out,
+				OnEvent: func(ev Event) {
+					if ev.Type == EventResponseReceived {
+						mu.Lock()
+						checked = append(checked, ev.Address)
+						mu.Unlock()
+					}
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(checked) != tt.wantChecked {
+				t.Errorf("checked %d targets, want %d: %v", len(checked), tt.wantChecked, checked)
+			}
+			// one JSON line per target
+			dec := json.NewDecoder(&out)
+			var lines int
+			for dec.More() {
+				var r checkResult
+				if err := dec.Decode(&r); err != nil {
+					t.Fatalf("Failed to decode the result: %v", err)
+				}
+				lines++
+			}
+			if lines != tt.wantChecked {
+				t.Errorf("got %d results, want %d", lines, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestRunClientStdinWithInterval(t *testing.T) {
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+	stdin = strings.NewReader("localhost:50051\n")
+	if err := runClient(context.Background(), CLIClient{Address: "-", Interval: time.Second}); err == nil {
+		t.Error("runClient() expected error, got nil")
+	}
+}
@@ -0,0 +1,140 @@
+package grpchealth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// runSummary is the summary of all the checks in a run for --summary
+type runSummary struct {
+	DeployID             string           `json:"deploy_id,omitempty"`
+	Total                int              `json:"total"`
+	Statuses             map[string]int   `json:"statuses"`
+	SuccessRate          float64          `json:"success_rate"`
+	FailurePercent       float64          `json:"failure_percent"`
+	FailThresholdPercent float64          `json:"fail_threshold_percent,omitempty"`
+	Latency              *latencySummary  `json:"latency_ms,omitempty"`
+	LatencyExceeded      int              `json:"latency_exceeded,omitempty"`
+	Unhealthy            []unhealthyCheck `json:"unhealthy"`
+}
+
+type latencySummary struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+type unhealthyCheck struct {
+	Address string `json:"address"`
+	Backend string `json:"backend,omitempty"`
+	Service string `json:"service"`
+	Reason  string `json:"reason"`
+	Error   string `json:"error,omitempty"`
+}
+
+// summarize aggregates the results. A check without a response is counted as ERROR.
+// The unhealthy targets are decided by the latest result of each target and service,
+// so a target that recovered during a --interval run is not listed.
+func summarize(results []*checkResult) runSummary {
+	s := runSummary{
+		Total:     len(results),
+		Statuses:  map[string]int{},
+		Unhealthy: []unhealthyCheck{},
+	}
+	var ok, responded int
+	var sum float64
+	type target struct{ address, backend, service string }
+	var targets []target
+	latest := map[target]*checkResult{}
+	for _, r := range results {
+		if r.Status == "" {
+			s.Statuses["ERROR"]++
+		} else {
+			s.Statuses[r.Status]++
+			if responded == 0 {
+				s.Latency = &latencySummary{Min: r.DurationMs, Max: r.DurationMs}
+			}
+			s.Latency.Min = min(s.Latency.Min, r.DurationMs)
+			s.Latency.Max = max(s.Latency.Max, r.DurationMs)
+			sum += r.DurationMs
+			responded++
+		}
+		if r.Error == "" {
+			ok++
+		}
+		if r.LatencyExceeded {
+			s.LatencyExceeded++
+		}
+		t := target{r.Address, r.Backend, r.Service}
+		if _, found := latest[t]; !found {
+			targets = append(targets, t)
+		}
+		latest[t] = r
+	}
+	for _, t := range targets {
+		if r := latest[t]; r.Error != "" {
+			s.Unhealthy = append(s.Unhealthy, unhealthyCheck{
+				Address: r.Address,
+				Backend: r.Backend,
+				Service: r.Service,
+				Reason:  r.Reason,
+				Error:   r.Error,
+			})
+		}
+	}
+	if responded > 0 {
+		s.Latency.Avg = sum / float64(responded)
+	}
+	if s.Total > 0 {
+		s.SuccessRate = float64(ok) / float64(s.Total)
+	}
+	s.FailurePercent = failurePercent(s.Total-ok, s.Total)
+	return s
+}
+
+func writeSummary(w io.Writer, opt CLIClient, results []*checkResult) error {
+	s := summarize(results)
+	s.FailThresholdPercent = opt.FailThresholdPercent
+	s.DeployID = opt.DeployID
+	return json.NewEncoder(w).Encode(s)
+}
+
+// writeResults writes the JUnit report and the summary of the collected results at the end of a run
+func writeResults(opt CLIClient, results []*checkResult) {
+	if opt.Output == "junit" {
+		if err := writeJUnit(opt.stdout(), opt.DeployID, results); err != nil {
+			slog.Warn("Failed to write the JUnit report", "error", err)
+		}
+	}
+	if !opt.Summary {
+		return
+	}
+	if opt.SummaryFile == "" {
+		if err := writeSummary(opt.stdout(), opt, results); err != nil {
+			slog.Warn("Failed to write the summary", "error", err)
+		}
+		return
+	}
+	if err := writeSummaryFile(opt, results); err != nil {
+		slog.Warn("Failed to write the summary", "file", opt.SummaryFile, "error", err)
+	}
+}
+
+func writeSummaryFile(opt CLIClient, results []*checkResult) error {
+	name := opt.SummaryFile
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := writeSummary(f, opt, results); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", name, err)
+	}
+	return nil
+}
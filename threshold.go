@@ -0,0 +1,38 @@
+package grpchealth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// batchError returns the error of a run over multiple targets with errs of the targets not healthy out of total.
+// The run fails only if the percentage of the failed targets exceeds --fail-threshold-percent.
+// The error wraps errs, so the reason and the exit code are of the failed targets.
+func batchError(opt CLIClient, errs []error, total int, kind string) error {
+	failed := len(errs)
+	if failed == 0 {
+		return nil
+	}
+	percent := failurePercent(failed, total)
+	if opt.FailThresholdPercent == 0 {
+		return fmt.Errorf("%d of %d %s are not healthy: %w", failed, total, kind, errors.Join(errs...))
+	}
+	if percent > opt.FailThresholdPercent {
+		return fmt.Errorf("%d of %d %s are not healthy (%.1f%% exceeds the threshold %g%%): %w", failed, total, kind, percent, opt.FailThresholdPercent, errors.Join(errs...))
+	}
+	slog.Warn("Unhealthy targets are within the threshold",
+		"failed", failed,
+		"total", total,
+		"failure_percent", percent,
+		"threshold_percent", opt.FailThresholdPercent,
+	)
+	return nil
+}
+
+func failurePercent(failed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(failed) / float64(total)
+}
@@ -0,0 +1,123 @@
+package grpchealth
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestBatchError(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold float64
+		failed    int
+		total     int
+		wantErr   bool
+	}{
+		{name: "all healthy", failed: 0, total: 500},
+		{name: "any failure fails without threshold", failed: 1, total: 500, wantErr: true},
+		{name: "within threshold", threshold: 1, failed: 5, total: 500},
+		{name: "equal to threshold", threshold: 1, failed: 5, total: 500},
+		{name: "exceeds threshold", threshold: 1, failed: 6, total: 500, wantErr: true},
+		{name: "all failed with 100 percent", threshold: 100, failed: 3, total: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := make([]error, tt.failed)
+			for i := range errs {
+				errs[i] = status.Error(codes.Unavailable, "connection refused")
+			}
+			err := batchError(CLIClient{FailThresholdPercent: tt.threshold}, errs, tt.total, "targets")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("batchError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && failureReason(err) != "connect_failed" {
+				t.Errorf("failureReason() = %s, want connect_failed of the targets", failureReason(err))
+			}
+		})
+	}
+}
+
+func TestRunClientFailThreshold(t *testing.T) {
+	serving := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+	// Get an address with no server listening
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closed := lis.Addr().String()
+	lis.Close()
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	tests := []struct {
+		name      string
+		threshold float64
+		wantErr   bool
+	}{
+		{name: "no threshold", wantErr: true},
+		{name: "within threshold", threshold: 50},
+		{name: "exceeds threshold", threshold: 49.9, wantErr: true},
+		{name: "invalid threshold", threshold: 101, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin = strings.NewReader(serving + "\n" + closed + "\n")
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := runClient(ctx, CLIClient{Address: "-", FailThresholdPercent: tt.threshold})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunClientBatchReason(t *testing.T) {
+	serving := startHealthServer(t, grpc_health_v1.HealthCheckResponse_SERVING)
+	notServing := startHealthServer(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	// Get addresses with no server listening
+	var closed []string
+	for range 2 {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		closed = append(closed, lis.Addr().String())
+		lis.Close()
+	}
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	tests := []struct {
+		name       string
+		targets    []string
+		wantReason string
+		wantCode   int
+	}{
+		{name: "all healthy", targets: []string{serving}, wantReason: "ok", wantCode: 0},
+		{name: "connection refused", targets: []string{serving, closed[0], closed[1]}, wantReason: "connect_failed", wantCode: 2},
+		{name: "not serving", targets: []string{notServing, serving}, wantReason: "not_serving", wantCode: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin = strings.NewReader(strings.Join(tt.targets, "\n") + "\n")
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := runClient(ctx, CLIClient{Address: "-", Concurrency: 2})
+			if got := failureReason(err); got != tt.wantReason {
+				t.Errorf("failureReason() = %s, want %s (error = %v)", got, tt.wantReason, err)
+			}
+			if got := ExitCode(err); got != tt.wantCode {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
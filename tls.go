@@ -0,0 +1,148 @@
+package grpchealth
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+var tlsRenegotiationSupport = map[string]tls.RenegotiationSupport{
+	"":       tls.RenegotiateNever,
+	"never":  tls.RenegotiateNever,
+	"once":   tls.RenegotiateOnceAsClient,
+	"freely": tls.RenegotiateFreelyAsClient,
+}
+
+// clientTLSConfig builds the TLS configuration for the client
+func clientTLSConfig(opt CLIClient) (*tls.Config, error) {
+	renegotiation, ok := tlsRenegotiationSupport[opt.TLSRenegotiation]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS renegotiation: %s", opt.TLSRenegotiation)
+	}
+	cfg := &tls.Config{
+		InsecureSkipVerify: opt.Insecure,
+		Renegotiation:      renegotiation,
+	}
+	if opt.ServerName != "" {
+		if opt.Insecure {
+			slog.Warn("Ignoring --server-name because certificate verification is disabled by --insecure", "server_name", opt.ServerName)
+		} else {
+			cfg.ServerName = opt.ServerName
+		}
+	}
+	if opt.CAFile != "" {
+		pool, err := loadCertPool(opt.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if opt.Cert != "" || opt.Key != "" {
+		if opt.Cert == "" || opt.Key == "" {
+			return nil, fmt.Errorf("both --cert and --key are required for a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(opt.Cert, opt.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if requested := opt.certificateRequested; requested != nil && len(cfg.Certificates) == 0 {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			requested.Store(true)
+			// no certificate is sent
+			return &tls.Certificate{}, nil
+		}
+	}
+	return cfg, nil
+}
+
+// loadCertPool loads the PEM encoded CA certificates in the file
+func loadCertPool(file string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no valid certificates found in CA file %s", file)
+	}
+	return pool, nil
+}
+
+// loadCRL loads the PEM or DER encoded certificate revocation list in the file
+func loadCRL(file string) (*x509.RevocationList, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL file: %w", err)
+	}
+	if block, _ := pem.Decode(b); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unexpected PEM block %q in CRL file %s", block.Type, file)
+		}
+		b = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL file %s: %w", file, err)
+	}
+	if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(time.Now()) {
+		slog.Warn("The CRL is past its next update", "file", file, "next_update", crl.NextUpdate)
+	}
+	return crl, nil
+}
+
+// checkCRLIssuer checks that the CRL is signed by one of the CA certificates in the file,
+// so that a CRL of another CA, which would never match, is not silently accepted
+func checkCRLIssuer(crl *x509.RevocationList, caFile string) error {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA file: %w", err)
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return fmt.Errorf("the CRL is not signed by a CA in %s", caFile)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if crl.CheckSignatureFrom(ca) == nil {
+			return nil
+		}
+	}
+}
+
+// verifyNotRevoked returns a tls.Config.VerifyPeerCertificate function that rejects
+// the verified chains with a certificate listed in the CRL.
+// The CRL applies only to the certificates of the CA that signed it.
+func verifyNotRevoked(crl *x509.RevocationList) func([][]byte, [][]*x509.Certificate) error {
+	revoked := make(map[string]bool, len(crl.RevokedCertificateEntries))
+	for _, entry := range crl.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.String()] = true
+	}
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			for i := 0; i+1 < len(chain); i++ {
+				cert, issuer := chain[i], chain[i+1]
+				if !bytes.Equal(cert.RawIssuer, crl.RawIssuer) || crl.CheckSignatureFrom(issuer) != nil {
+					continue
+				}
+				if revoked[cert.SerialNumber.String()] {
+					return fmt.Errorf("certificate %q (serial %s) is revoked", cert.Subject, cert.SerialNumber)
+				}
+			}
+		}
+		return nil
+	}
+}
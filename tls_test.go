@@ -0,0 +1,574 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestClientTLSConfig(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	tests := []struct {
+		name              string
+		opt               CLIClient
+		wantInsecure      bool
+		wantRenegotiation tls.RenegotiationSupport
+		wantCerts         int
+		wantRootCAs       bool
+		wantServerName    string
+		wantErr           bool
+	}{
+		{
+			name:              "default",
+			opt:               CLIClient{TLS: true},
+			wantRenegotiation: tls.RenegotiateNever,
+		},
+		{
+			name:              "insecure",
+			opt:               CLIClient{TLS: true, Insecure: true},
+			wantInsecure:      true,
+			wantRenegotiation: tls.RenegotiateNever,
+		},
+		{
+			name:              "renegotiate once",
+			opt:               CLIClient{TLS: true, TLSRenegotiation: "once"},
+			wantRenegotiation: tls.RenegotiateOnceAsClient,
+		},
+		{
+			name:              "renegotiate freely",
+			opt:               CLIClient{TLS: true, TLSRenegotiation: "freely"},
+			wantRenegotiation: tls.RenegotiateFreelyAsClient,
+		},
+		{
+			name:              "client certificate",
+			opt:               CLIClient{TLS: true, Cert: certFile, Key: keyFile},
+			wantRenegotiation: tls.RenegotiateNever,
+			wantCerts:         1,
+		},
+		{
+			name:              "custom CA",
+			opt:               CLIClient{TLS: true, CAFile: certFile},
+			wantRenegotiation: tls.RenegotiateNever,
+			wantRootCAs:       true,
+		},
+		{
+			name:              "server name",
+			opt:               CLIClient{TLS: true, ServerName: "api.example.com"},
+			wantRenegotiation: tls.RenegotiateNever,
+			wantServerName:    "api.example.com",
+		},
+		{
+			name:              "server name ignored with insecure",
+			opt:               CLIClient{TLS: true, Insecure: true, ServerName: "api.example.com"},
+			wantInsecure:      true,
+			wantRenegotiation: tls.RenegotiateNever,
+		},
+		{
+			name:    "missing CA file",
+			opt:     CLIClient{TLS: true, CAFile: "/nonexistent/ca.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "CA file without certificates",
+			opt:     CLIClient{TLS: true, CAFile: keyFile},
+			wantErr: true,
+		},
+		{
+			name:    "cert without key",
+			opt:     CLIClient{TLS: true, Cert: certFile},
+			wantErr: true,
+		},
+		{
+			name:    "key without cert",
+			opt:     CLIClient{TLS: true, Key: keyFile},
+			wantErr: true,
+		},
+		{
+			name:    "missing cert file",
+			opt:     CLIClient{TLS: true, Cert: "/nonexistent/cert.pem", Key: keyFile},
+			wantErr: true,
+		},
+		{
+			name:    "invalid renegotiation",
+			opt:     CLIClient{TLS: true, TLSRenegotiation: "always"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := clientTLSConfig(tt.opt)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if cfg.InsecureSkipVerify != tt.wantInsecure {
+				t.Errorf("InsecureSkipVerify = %v, want %v", cfg.InsecureSkipVerify, tt.wantInsecure)
+			}
+			if cfg.Renegotiation != tt.wantRenegotiation {
+				t.Errorf("Renegotiation = %v, want %v", cfg.Renegotiation, tt.wantRenegotiation)
+			}
+			if cfg.ServerName != tt.wantServerName {
+				t.Errorf("ServerName = %q, want %q", cfg.ServerName, tt.wantServerName)
+			}
+			if (cfg.RootCAs != nil) != tt.wantRootCAs {
+				t.Errorf("RootCAs = %v, wantRootCAs %v", cfg.RootCAs, tt.wantRootCAs)
+			}
+			if len(cfg.Certificates) != tt.wantCerts {
+				t.Errorf("len(Certificates) = %d, want %d", len(cfg.Certificates), tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestRunClientMutualTLS(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name    string
+		opt     CLIClient
+		wantErr bool
+	}{
+		{
+			name: "with client certificate",
+			opt:  CLIClient{Cert: certFile, Key: keyFile},
+		},
+		{
+			name:    "without client certificate",
+			opt:     CLIClient{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			opt := tt.opt
+			opt.Address = lis.Addr().String()
+			opt.TLS = true
+			opt.Insecure = true
+			err := runClient(ctx, opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunClientCAFile(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	// The server certificate is valid for localhost and 127.0.0.1
+	port := strconv.Itoa(lis.Addr().(*net.TCPAddr).Port)
+	tests := []struct {
+		name       string
+		address    string
+		caFile     string
+		serverName string
+		wantErr    bool
+	}{
+		// The self-signed server certificate is its own CA
+		{name: "verified with the CA file", address: lis.Addr().String(), caFile: certFile},
+		{name: "unknown authority without the CA file", address: lis.Addr().String(), wantErr: true},
+		{name: "server name matches the certificate", address: lis.Addr().String(), caFile: certFile, serverName: "localhost"},
+		{name: "server name does not match the certificate", address: lis.Addr().String(), caFile: certFile, serverName: "api.example.com", wantErr: true},
+		{name: "server name overrides the dial host", address: net.JoinHostPort("127.0.0.2", port), caFile: certFile, serverName: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := runClient(ctx, CLIClient{
+				Address:    tt.address,
+				TLS:        true,
+				CAFile:     tt.caFile,
+				ServerName: tt.serverName,
+				ContextDialer: func(ctx context.Context, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "tcp", lis.Addr().String())
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunServerClientCA(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		// The self-signed certificate is the server certificate, the client certificate, and both CAs
+		errCh <- runServer(ctx, CLIServer{
+			Address:  address,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			ClientCA: certFile,
+		})
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	// A client certificate not signed by the client CA
+	otherCert, otherKey, otherCleanup := createTempCertFiles(t)
+	defer otherCleanup()
+
+	tests := []struct {
+		name    string
+		opt     CLIClient
+		wantErr bool
+	}{
+		{name: "verified client certificate", opt: CLIClient{Cert: certFile, Key: keyFile}},
+		{name: "without client certificate", wantErr: true},
+		{name: "unknown client certificate", opt: CLIClient{Cert: otherCert, Key: otherKey}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := tt.opt
+			opt.Address = address
+			opt.TLS = true
+			opt.CAFile = certFile
+			err := runClient(ctx, opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("runServer() error = %v", err)
+	}
+}
+
+func TestRunServerClientCAErrors(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	caFile := newTestCA(t, "client-ca").writeCert(t, dir)
+	otherCRL := writePEMFile(t, dir, "other-crl.pem", "X509 CRL", newTestCA(t, "other-ca").createCRL(t))
+
+	tests := []struct {
+		name string
+		opt  CLIServer
+	}{
+		{name: "without server certificate", opt: CLIServer{ClientCA: certFile}},
+		{name: "without server key", opt: CLIServer{CertFile: certFile, ClientCA: certFile}},
+		{name: "missing client CA", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, ClientCA: "/nonexistent/ca.pem"}},
+		{name: "client CA without certificates", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, ClientCA: keyFile}},
+		{name: "client CRL without client CA", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, ClientCRL: otherCRL}},
+		{name: "missing client CRL", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, ClientCA: caFile, ClientCRL: "/nonexistent/crl.pem"}},
+		{name: "invalid client CRL", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, ClientCA: caFile, ClientCRL: caFile}},
+		{name: "client CRL of another CA", opt: CLIServer{CertFile: certFile, KeyFile: keyFile, ClientCA: caFile, ClientCRL: otherCRL}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			opt := tt.opt
+			opt.Address = "127.0.0.1:0"
+			if err := runServer(ctx, opt); err == nil {
+				t.Error("runServer() expected error, got nil")
+			}
+		})
+	}
+}
+
+// testCA is a CA issuing client certificates and CRLs for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// writeCert writes the CA certificate to a file in dir
+func (ca *testCA) writeCert(t *testing.T, dir string) string {
+	t.Helper()
+	return writePEMFile(t, dir, ca.cert.Subject.CommonName+".pem", "CERTIFICATE", ca.cert.Raw)
+}
+
+// issueClientCert issues a client certificate with the serial number and writes the key pair to files in dir
+func (ca *testCA) issueClientCert(t *testing.T, dir string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate client key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client-" + strconv.FormatInt(serial, 10)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal client key: %v", err)
+	}
+	name := template.Subject.CommonName
+	return writePEMFile(t, dir, name+".pem", "CERTIFICATE", der), writePEMFile(t, dir, name+"-key.pem", "PRIVATE KEY", keyDER)
+}
+
+// createCRL creates a CRL revoking the serial numbers
+func (ca *testCA) createCRL(t *testing.T, serials ...int64) []byte {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, serial := range serials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	return der
+}
+
+func writePEMFile(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRunServerClientCRL(t *testing.T) {
+	certFile, keyFile, cleanup := createTempCertFiles(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	ca := newTestCA(t, "client-ca")
+	caFile := ca.writeCert(t, dir)
+	validCert, validKey := ca.issueClientCert(t, dir, 2)
+	revokedCert, revokedKey := ca.issueClientCert(t, dir, 3)
+	crl := ca.createCRL(t, 3)
+	pemCRL := writePEMFile(t, dir, "crl.pem", "X509 CRL", crl)
+	derCRL := filepath.Join(dir, "crl.der")
+	if err := os.WriteFile(derCRL, crl, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, crlFile := range []string{pemCRL, derCRL} {
+		t.Run(filepath.Ext(crlFile), func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to create listener: %v", err)
+			}
+			address := lis.Addr().String()
+			lis.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runServer(ctx, CLIServer{
+					Address:   address,
+					CertFile:  certFile,
+					KeyFile:   keyFile,
+					ClientCA:  caFile,
+					ClientCRL: crlFile,
+				})
+			}()
+
+			// Give server time to start
+			time.Sleep(100 * time.Millisecond)
+
+			tests := []struct {
+				name    string
+				cert    string
+				key     string
+				wantErr bool
+			}{
+				{name: "valid client certificate", cert: validCert, key: validKey},
+				{name: "revoked client certificate", cert: revokedCert, key: revokedKey, wantErr: true},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					err := runClient(ctx, CLIClient{
+						Address: address,
+						TLS:     true,
+						CAFile:  certFile,
+						Cert:    tt.cert,
+						Key:     tt.key,
+					})
+					if (err != nil) != tt.wantErr {
+						t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+					}
+				})
+			}
+
+			cancel()
+			if err := <-errCh; err != nil {
+				t.Errorf("runServer() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyNotRevoked(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "client-ca")
+	other := newTestCA(t, "other-ca")
+	certFile, _ := ca.issueClientCert(t, dir, 3)
+	leaf, err := loadCertificateFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		crl     []byte
+		wantErr bool
+	}{
+		{name: "revoked", crl: ca.createCRL(t, 3), wantErr: true},
+		{name: "not revoked", crl: ca.createCRL(t, 2)},
+		{name: "empty CRL", crl: ca.createCRL(t)},
+		// the same serial number issued by another CA is not revoked
+		{name: "CRL of another CA", crl: other.createCRL(t, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crl, err := x509.ParseRevocationList(tt.crl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = verifyNotRevoked(crl)(nil, [][]*x509.Certificate{{leaf, ca.cert}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyNotRevoked() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func loadCertificateFile(file string) (*x509.Certificate, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	return x509.ParseCertificate(block.Bytes)
+}
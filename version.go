@@ -0,0 +1,76 @@
+package grpchealth
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/alecthomas/kong"
+)
+
+// Version, Commit and BuildDate describe the build of grpchealth, set at build time via -ldflags
+// (e.g., -X github.com/fujiwara/grpchealth.Commit=abc1234).
+// Unset values fall back to the module version and the VCS information embedded by the go command.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+type CLIVersion struct{}
+
+// versionFlag prints the version and exits like --help
+type versionFlag bool
+
+func (versionFlag) BeforeApply(app *kong.Kong) error {
+	fmt.Fprintln(app.Stdout, versionString())
+	app.Exit(0)
+	return nil
+}
+
+// buildInfo returns the version, the commit and the build date of the binary
+func buildInfo() (version, commit, date string) {
+	version, commit, date = Version, Commit, BuildDate
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		// installed by go install module@version
+		version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && commit == "":
+			commit = s.Value
+		case s.Key == "vcs.time" && date == "":
+			// the commit time is the closest to the build date without -ldflags
+			date = s.Value
+		}
+	}
+	return
+}
+
+// buildVersion returns the version of the binary, as shown by the version command
+func buildVersion() string {
+	version, _, _ := buildInfo()
+	return version
+}
+
+func versionString() string {
+	version, commit, date := buildInfo()
+	if commit == "" {
+		commit = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("grpchealth %s (commit: %s, built: %s, %s %s/%s)",
+		version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+func runVersion(w io.Writer) error {
+	_, err := fmt.Fprintln(w, versionString())
+	return err
+}
@@ -0,0 +1,170 @@
+package grpchealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// watchHealth streams the health status with the Watch RPC and logs each transition
+// until the context is canceled or the server closes the stream
+func watchHealth(ctx context.Context, client grpc_health_v1.HealthClient, req *grpc_health_v1.HealthCheckRequest, ev *eventEmitter) error {
+	slog.Info("Watching health status", "service", req.GetService())
+	stream, err := client.Watch(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("health watch request failed: %w", err)
+	}
+	var last grpc_health_v1.HealthCheckResponse_ServingStatus
+	for first := true; ; first = false {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			slog.Info("Health watch stream closed by the server", "service", req.GetService())
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				slog.Info("Stopped watching health status", "service", req.GetService())
+				return nil
+			}
+			return fmt.Errorf("health watch stream failed: %w", err)
+		}
+		current := resp.GetStatus()
+		ev.emit(Event{Type: EventResponseReceived, Status: current})
+		if first {
+			slog.Info("Received health status", "service", req.GetService(), "status", current.String())
+		} else {
+			slog.Info("Health status changed",
+				"service", req.GetService(),
+				"from", last.String(),
+				"to", current.String(),
+			)
+		}
+		last = current
+	}
+}
+
+// streamsWatchEvents reports whether --watch writes each received status as a JSON line
+func streamsWatchEvents(opt CLIClient) bool {
+	return opt.Watch && opt.ExpectTransition == "" && (opt.Output == "json" || opt.jsonFile != nil)
+}
+
+// watchSequence numbers the statuses received by --watch, continuing across the reconnects
+// of the retries unless it is reset
+type watchSequence struct {
+	seq int64
+}
+
+func (s *watchSequence) reset() {
+	s.seq = 0
+}
+
+// handler returns the event handler that writes each received status as a JSON line
+// with the sequence number and the time of the event, so consumers can order them and detect gaps
+func (s *watchSequence) handler(opt CLIClient) func(Event) {
+	// the JUnit report and the summary have the result of the whole watch
+	opt.results = nil
+	return func(ev Event) {
+		if ev.Type != EventResponseReceived {
+			return
+		}
+		s.seq++
+		r := newCheckResult(opt)
+		r.observe(ev)
+		r.Seq = s.seq
+		r.EventTime = ev.Time.Format(time.RFC3339Nano)
+		var err error
+		if ev.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			err = &notServingError{service: opt.Service, status: ev.Status}
+		}
+		writeResult(opt, r, err)
+	}
+}
+
+// parseTransition parses a transition in the form of FROM->TO (e.g., NOT_SERVING->SERVING)
+func parseTransition(s string) (from, to grpc_health_v1.HealthCheckResponse_ServingStatus, err error) {
+	f, t, ok := strings.Cut(s, "->")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid transition %q: must be FROM->TO (e.g., NOT_SERVING->SERVING)", s)
+	}
+	fv, ok := grpc_health_v1.HealthCheckResponse_ServingStatus_value[strings.TrimSpace(f)]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid transition %q: unknown status %q", s, f)
+	}
+	tv, ok := grpc_health_v1.HealthCheckResponse_ServingStatus_value[strings.TrimSpace(t)]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid transition %q: unknown status %q", s, t)
+	}
+	if fv == tv {
+		return 0, 0, fmt.Errorf("invalid transition %q: statuses must differ", s)
+	}
+	return grpc_health_v1.HealthCheckResponse_ServingStatus(fv), grpc_health_v1.HealthCheckResponse_ServingStatus(tv), nil
+}
+
+// expectTransition watches the health status and succeeds when the status changes
+// from the FROM status to the TO status within the duration
+func expectTransition(ctx context.Context, client grpc_health_v1.HealthClient, req *grpc_health_v1.HealthCheckRequest, transition string, within time.Duration, ev *eventEmitter) error {
+	from, to, err := parseTransition(transition)
+	if err != nil {
+		return err
+	}
+	// the window is timed on clk, and ends the watch with DeadlineExceeded like a timeout
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-clk.After(within):
+			cancel(context.DeadlineExceeded)
+		}
+	}()
+
+	slog.Info("Waiting for health status transition",
+		"service", req.GetService(),
+		"from", from.String(),
+		"to", to.String(),
+		"within", within,
+	)
+	stream, err := client.Watch(ctx, req)
+	if err != nil {
+		return fmt.Errorf("health watch request failed: %w", err)
+	}
+	start := clk.Now()
+	var seenFrom bool
+	last := grpc_health_v1.HealthCheckResponse_UNKNOWN
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("transition %s->%s did not happen within %s (last status: %s): %w", from, to, within, last, context.Cause(ctx))
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("transition %s->%s did not happen before the server closed the stream (last status: %s)", from, to, last)
+			}
+			return fmt.Errorf("health watch stream failed: %w", err)
+		}
+		last = resp.GetStatus()
+		ev.emit(Event{Type: EventResponseReceived, Status: last})
+		slog.Info("Received health status", "service", req.GetService(), "status", last.String())
+		switch {
+		case last == from:
+			seenFrom = true
+		case last == to && seenFrom:
+			slog.Info("Health status transition observed",
+				"service", req.GetService(),
+				"from", from.String(),
+				"to", to.String(),
+				"elapsed", clk.Now().Sub(start),
+			)
+			return nil
+		}
+	}
+}
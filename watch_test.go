@@ -0,0 +1,389 @@
+package grpchealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunClientWatch(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	statuses := make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watchCtx, stop := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runClient(watchCtx, CLIClient{
+			Address: lis.Addr().String(),
+			Watch:   true,
+			OnEvent: func(ev Event) {
+				if ev.Type == EventResponseReceived {
+					statuses <- ev.Status
+				}
+			},
+		})
+	}()
+
+	want := []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+		grpc_health_v1.HealthCheckResponse_SERVING,
+	}
+	for i, w := range want {
+		select {
+		case got := <-statuses:
+			if got != w {
+				t.Fatalf("status #%d = %s, want %s", i, got, w)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for status #%d", i)
+		}
+		if i+1 < len(want) {
+			healthServer.SetServingStatus("", want[i+1])
+		}
+	}
+
+	// Canceling the context stops watching without an error
+	stop()
+	if err := <-errCh; err != nil {
+		t.Errorf("runClient() error = %v, want nil", err)
+	}
+}
+
+func TestRunClientWatchMultipleServices(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("a", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("b", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	statuses := make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watchCtx, stop := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		// --timeout does not bound watching, so the first service is watched past it
+		errCh <- runClient(watchCtx, CLIClient{
+			Address:  lis.Addr().String(),
+			Services: []string{"a", "b"},
+			Watch:    true,
+			Timeout:  200 * time.Millisecond,
+			OnEvent: func(ev Event) {
+				if ev.Type == EventResponseReceived {
+					statuses <- ev.Status
+				}
+			},
+		})
+	}()
+
+	select {
+	case <-statuses:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the first status")
+	}
+	time.Sleep(500 * time.Millisecond)
+	healthServer.SetServingStatus("a", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	select {
+	case got := <-statuses:
+		if got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("status = %s, want %s", got, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		}
+	case err := <-errCh:
+		t.Fatalf("runClient() returned before the status changed: %v", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the status change")
+	}
+
+	stop()
+	if err := <-errCh; err != nil {
+		t.Errorf("runClient() error = %v, want nil", err)
+	}
+}
+
+func TestRunClientWatchJSONLines(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	received := make(chan struct{}, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watchCtx, stop := context.WithCancel(ctx)
+	var buf bytes.Buffer
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runClient(watchCtx, CLIClient{
+			Address: lis.Addr().String(),
+			Watch:   true,
+			Output:  "json",
+			Stdout:  &buf,
+			OnEvent: func(ev Event) {
+				if ev.Type == EventResponseReceived {
+					received <- struct{}{}
+				}
+			},
+		})
+	}()
+
+	wait := func() {
+		t.Helper()
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a status")
+		}
+	}
+	wait()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	wait()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	wait()
+	stop()
+	if err := <-errCh; err != nil {
+		t.Fatalf("runClient() error = %v, want nil", err)
+	}
+
+	// A line for each received status, and no line for the end of the watch
+	results := decodeResults(t, buf.String())
+	var statuses []string
+	for i, r := range results {
+		statuses = append(statuses, r.Status)
+		if r.Seq != int64(i+1) {
+			t.Errorf("line #%d seq = %d, want %d", i+1, r.Seq, i+1)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, r.EventTime); err != nil {
+			t.Errorf("line #%d event_time = %q: %v", i+1, r.EventTime, err)
+		}
+	}
+	if want := []string{"SERVING", "NOT_SERVING", "SERVING"}; !slices.Equal(statuses, want) {
+		t.Errorf("statuses = %q, want %q", statuses, want)
+	}
+	if results[1].Reason != "not_serving" {
+		t.Errorf("reason of NOT_SERVING = %q, want not_serving", results[1].Reason)
+	}
+}
+
+// failingWatchServer sends a single status and then fails the Watch stream like a restarting server
+type failingWatchServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (failingWatchServer) Watch(_ *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	return status.Error(codes.Unavailable, "restarting")
+}
+
+func TestRunClientWatchSeqOnReconnect(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, failingWatchServer{})
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	tests := []struct {
+		name     string
+		resetSeq bool
+		wantSeqs []int64
+	}{
+		{name: "continue", wantSeqs: []int64{1, 2, 3, 0}},
+		{name: "reset", resetSeq: true, wantSeqs: []int64{1, 1, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var buf bytes.Buffer
+			err := runClient(ctx, CLIClient{
+				Address:       lis.Addr().String(),
+				Watch:         true,
+				Output:        "json",
+				Stdout:        &buf,
+				Retries:       2,
+				RetryInterval: 10 * time.Millisecond,
+				ResetSeq:      tt.resetSeq,
+			})
+			if err == nil {
+				t.Fatal("runClient() expected error, got nil")
+			}
+			// The last line is the failure of the watch after the retries
+			results := decodeResults(t, buf.String())
+			var seqs []int64
+			for _, r := range results {
+				seqs = append(seqs, r.Seq)
+			}
+			if !slices.Equal(seqs, tt.wantSeqs) {
+				t.Errorf("seqs = %v, want %v", seqs, tt.wantSeqs)
+			}
+			if last := results[len(results)-1]; last.Reason != "connect_failed" {
+				t.Errorf("reason of the last line = %q, want connect_failed", last.Reason)
+			}
+		})
+	}
+}
+
+func decodeResults(t *testing.T, out string) []checkResult {
+	t.Helper()
+	var results []checkResult
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var r checkResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Result is not a JSON object: %v: %s", err, line)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// closingWatchServer sends a single status and then ends the Watch stream
+type closingWatchServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (closingWatchServer) Watch(_ *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func TestRunClientWatchServerClose(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, closingWatchServer{})
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The end of the stream (io.EOF) is a clean close
+	err = runClient(ctx, CLIClient{Address: lis.Addr().String(), Watch: true})
+	if err != nil {
+		t.Errorf("runClient() error = %v, want nil", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("runClient() returned only after the context was done")
+	}
+}
+
+func TestRunClientExpectTransition(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("restarting", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus("stuck", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus("up", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Logf("Server stopped: %v", err)
+		}
+	}()
+	defer s.Stop()
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		healthServer.SetServingStatus("restarting", grpc_health_v1.HealthCheckResponse_SERVING)
+	})
+
+	tests := []struct {
+		name       string
+		service    string
+		transition string
+		wantErr    bool
+	}{
+		{name: "recovered", service: "restarting", transition: "NOT_SERVING->SERVING"},
+		{name: "never recovered", service: "stuck", transition: "NOT_SERVING->SERVING", wantErr: true},
+		{name: "already serving is not a transition", service: "up", transition: "NOT_SERVING->SERVING", wantErr: true},
+		{name: "invalid transition", service: "up", transition: "SERVING", wantErr: true},
+		{name: "unknown status", service: "up", transition: "DOWN->SERVING", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runClient(context.Background(), CLIClient{
+				Address:          lis.Addr().String(),
+				Service:          tt.service,
+				ExpectTransition: tt.transition,
+				Within:           500 * time.Millisecond,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}